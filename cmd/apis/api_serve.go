@@ -6,11 +6,22 @@ import (
 	"github.com/seal-io/kubecia/pkg/apis/server"
 	"github.com/seal-io/kubecia/pkg/plugins/aws"
 	"github.com/seal-io/kubecia/pkg/plugins/azure"
+	"github.com/seal-io/kubecia/pkg/plugins/digitalocean"
 	"github.com/seal-io/kubecia/pkg/plugins/gcp"
+	"github.com/seal-io/kubecia/pkg/plugins/kubernetes"
+	"github.com/seal-io/kubecia/pkg/plugins/linode"
+	"github.com/seal-io/kubecia/pkg/plugins/local"
+	"github.com/seal-io/kubecia/pkg/plugins/profile"
+	"github.com/seal-io/kubecia/pkg/plugins/vault"
 )
 
 func NewServe() *cobra.Command {
-	var srv server.Server
+	var (
+		srv  server.Server
+		lsrv local.Server
+		ksrv kubernetes.Server
+		psrv profile.Server
+	)
 
 	c := &cobra.Command{
 		Use:          "serve",
@@ -21,6 +32,12 @@ func NewServe() *cobra.Command {
 				aws.Serve,
 				azure.Serve,
 				gcp.Serve,
+				digitalocean.Serve,
+				linode.Serve,
+				ksrv.Serve,
+				vault.Serve,
+				lsrv.Serve,
+				psrv.Serve,
 			}
 
 			for i := range ss {
@@ -32,6 +49,9 @@ func NewServe() *cobra.Command {
 	}
 
 	srv.AddFlags(c.Flags())
+	ksrv.AddFlags(c.Flags())
+	lsrv.AddFlags(c.Flags())
+	psrv.AddFlags(c.Flags())
 
 	return c
 }
@@ -0,0 +1,384 @@
+package caches
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/seal-io/kubecia/pkg/apis"
+	"github.com/seal-io/kubecia/pkg/apis/server"
+	"github.com/seal-io/kubecia/pkg/bytespool"
+	"github.com/seal-io/kubecia/pkg/cache"
+	"github.com/seal-io/kubecia/pkg/cache/cachetest"
+	"github.com/seal-io/kubecia/pkg/consts"
+	"github.com/seal-io/kubecia/pkg/json"
+	"github.com/seal-io/kubecia/pkg/token"
+	"github.com/seal-io/kubecia/pkg/version"
+)
+
+func AddCommands(c *cobra.Command) {
+	var (
+		g = &cobra.Group{
+			ID:    "cache",
+			Title: `Cache commands`,
+		}
+		cs = []*cobra.Command{
+			NewCache(),
+		}
+	)
+
+	c.AddGroup(g)
+
+	for i := range cs {
+		cs[i].GroupID = g.ID
+		c.AddCommand(cs[i])
+	}
+}
+
+type options struct {
+	Cache  string
+	Remote bool
+	Socket string
+	Output string
+}
+
+func (o *options) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&o.Cache, "cache", "file://", "Cache DSN to operate, e.g. file:///path?buckets=12")
+	flags.BoolVar(&o.Remote, "remote", false, "Operate the cache of the central service via the admin APIs")
+	flags.StringVar(&o.Socket, "socket", consts.SocketPath(),
+		"Socket path or URL of the central service, e.g. /var/run/kubecia.sock or https://kubecia.example.com:8443")
+	flags.StringVarP(&o.Output, "output", "o", "table", "Output format, select from table and json")
+}
+
+// do performs the given operation on the local cache,
+// or requests the admin API of the central service if remote.
+func (o *options) do(
+	ctx context.Context,
+	method, path string,
+	query url.Values,
+	local func(context.Context, cache.Cache) (any, error),
+	into any,
+) error {
+	if !o.Remote {
+		c, err := cache.Open(ctx, o.Cache)
+		if err != nil {
+			return fmt.Errorf("error creating cache: %w", err)
+		}
+
+		defer func() { _ = c.Close() }()
+
+		v, err := local(ctx, c)
+		if err != nil {
+			return err
+		}
+
+		return json.Unmarshal(json.MustMarshal(v), into)
+	}
+
+	u := apis.Route(server.AdminNamespace, path)
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return fmt.Errorf("error creating remote request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", version.Get())
+
+	resp, err := apis.Client(o.Socket).Do(req)
+	if err != nil {
+		return fmt.Errorf("error making remote request: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	buf := bytespool.GetBuffer()
+	defer bytespool.Put(buf)
+
+	_, err = io.Copy(buf, resp.Body)
+	if err != nil {
+		return fmt.Errorf("error copying response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error response from remote: %s: %s",
+			resp.Status, strings.TrimSpace(buf.String()))
+	}
+
+	return json.Unmarshal(buf.Bytes(), into)
+}
+
+func (o *options) print(c *cobra.Command, v any, table func(w io.Writer)) error {
+	switch o.Output {
+	case "json":
+		c.Println(string(json.MustMarshalIndent(v, "", "  ")))
+	case "table":
+		tw := tabwriter.NewWriter(c.OutOrStdout(), 0, 4, 2, ' ', 0)
+		table(tw)
+
+		return tw.Flush()
+	default:
+		return fmt.Errorf("invalid output format %q", o.Output)
+	}
+
+	return nil
+}
+
+func NewCache() *cobra.Command {
+	var o options
+
+	c := &cobra.Command{
+		Use:          "cache",
+		Short:        "Operate the cached tokens.",
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			return c.Help()
+		},
+	}
+
+	o.AddFlags(c.PersistentFlags())
+
+	c.AddCommand(
+		newList(&o),
+		newInspect(&o),
+		newRemove(&o),
+		newPurge(&o),
+		newPrune(&o),
+		newStats(&o),
+		newCheck(&o),
+	)
+
+	return c
+}
+
+func newList(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:          "ls [pattern]",
+		Short:        "List the cached tokens, whose key matches the given pattern.",
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			var (
+				pattern string
+				es      []token.Entry
+			)
+
+			if len(args) != 0 {
+				pattern = args[0]
+			}
+
+			err := o.do(c.Context(), http.MethodGet, "cache", url.Values{"pattern": {pattern}},
+				func(ctx context.Context, cc cache.Cache) (any, error) {
+					return token.ListEntries(ctx, cc, pattern)
+				}, &es)
+			if err != nil {
+				return err
+			}
+
+			return o.print(c, es, func(w io.Writer) {
+				_, _ = fmt.Fprintln(w, "PROVIDER\tIDENTITY\tCLUSTER\tEXPIRES IN\tKEY")
+
+				for i := range es {
+					_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+						es[i].Provider, orNone(es[i].Identity), orNone(es[i].Cluster),
+						time.Until(es[i].CacheExpiration).Round(time.Second), es[i].Key)
+				}
+			})
+		},
+	}
+}
+
+func newInspect(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:          "inspect <key>",
+		Short:        "Inspect the cached token of the given key, the secrets are redacted.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			var e token.Entry
+
+			err := o.do(c.Context(), http.MethodGet, "cache/inspect", url.Values{"key": {args[0]}},
+				func(ctx context.Context, cc cache.Cache) (any, error) {
+					return token.InspectEntry(ctx, cc, args[0])
+				}, &e)
+			if err != nil {
+				if errors.Is(err, cache.ErrEntryNotFound) {
+					return fmt.Errorf("%s is not found", args[0])
+				}
+
+				return err
+			}
+
+			// Always print in JSON, the metadata is nested.
+			c.Println(string(json.MustMarshalIndent(e, "", "  ")))
+
+			return nil
+		},
+	}
+}
+
+func newRemove(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:          "rm <pattern>",
+		Short:        "Remove the cached tokens, whose key matches the given pattern.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			var ks []string
+
+			err := o.do(c.Context(), http.MethodDelete, "cache", url.Values{"pattern": {args[0]}},
+				func(ctx context.Context, cc cache.Cache) (any, error) {
+					return token.RemoveEntries(ctx, cc, args[0])
+				}, &ks)
+			if err != nil {
+				return err
+			}
+
+			return printRemoved(c, o, ks)
+		},
+	}
+}
+
+func newPurge(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:          "purge",
+		Short:        "Remove all cached tokens.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			var ks []string
+
+			err := o.do(c.Context(), http.MethodPost, "cache/purge", nil,
+				func(ctx context.Context, cc cache.Cache) (any, error) {
+					return token.RemoveEntries(ctx, cc, "*")
+				}, &ks)
+			if err != nil {
+				return err
+			}
+
+			return printRemoved(c, o, ks)
+		},
+	}
+}
+
+func newPrune(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:          "prune",
+		Short:        "Remove the expired entries.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			var n int
+
+			err := o.do(c.Context(), http.MethodPost, "cache/prune", nil,
+				func(ctx context.Context, cc cache.Cache) (any, error) {
+					return token.PruneEntries(ctx, cc)
+				}, &n)
+			if err != nil {
+				return err
+			}
+
+			return o.print(c, map[string]int{"pruned": n}, func(w io.Writer) {
+				_, _ = fmt.Fprintf(w, "pruned %d expired entries\n", n)
+			})
+		},
+	}
+}
+
+func newStats(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:          "stats",
+		Short:        "Show the statistics of the cache.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			var s cache.Stats
+
+			err := o.do(c.Context(), http.MethodGet, "cache/stats", nil,
+				func(ctx context.Context, cc cache.Cache) (any, error) {
+					return token.GetStats(ctx, cc)
+				}, &s)
+			if err != nil {
+				return err
+			}
+
+			return o.print(c, s, func(w io.Writer) {
+				var ratio float64
+				if n := s.Hits + s.Misses; n != 0 {
+					ratio = float64(s.Hits) / float64(n) * 100
+				}
+
+				_, _ = fmt.Fprintf(w, "HITS\t%d\n", s.Hits)
+				_, _ = fmt.Fprintf(w, "MISSES\t%d\n", s.Misses)
+				_, _ = fmt.Fprintf(w, "HIT RATIO\t%.2f%%\n", ratio)
+				_, _ = fmt.Fprintf(w, "SETS\t%d\n", s.Sets)
+
+				for _, r := range []string{cache.EvictionExpired, cache.EvictionDeleted, cache.EvictionNoSpace} {
+					_, _ = fmt.Fprintf(w, "EVICTIONS (%s)\t%d\n", strings.ToUpper(r), s.Evictions[r])
+				}
+
+				_, _ = fmt.Fprintf(w, "ENTRIES\t%d\n", s.Entries)
+				_, _ = fmt.Fprintf(w, "BYTES\t%s\n", humanize.IBytes(uint64(s.Bytes)))
+			})
+		},
+	}
+}
+
+func newCheck(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "check",
+		Short: "Check the cache conforms to the semantics, the checking entries are removed at the end.",
+		Long: "Check the cache conforms to the semantics, " +
+			"like not-found, too-big, TTL expiry, delete, namespaces, concurrency and close, " +
+			"the checking entries are removed at the end, " +
+			"pruning is not checked as it evicts the expired entries of others sharing the cache.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			if o.Remote {
+				return errors.New("checking is not supported remotely")
+			}
+
+			err := cachetest.TestCache(c.Context(), cachetest.OpenDSN(o.Cache))
+			if err != nil {
+				return fmt.Errorf("nonconforming cache:\n%w", err)
+			}
+
+			c.Println("conforming")
+
+			return nil
+		},
+	}
+}
+
+func printRemoved(c *cobra.Command, o *options, ks []string) error {
+	return o.print(c, ks, func(w io.Writer) {
+		for i := range ks {
+			_, _ = fmt.Fprintf(w, "removed %s\n", ks[i])
+		}
+
+		if len(ks) == 0 {
+			_, _ = fmt.Fprintln(w, "nothing removed")
+		}
+	})
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+
+	return s
+}
@@ -2,6 +2,9 @@ package plugins
 
 import (
 	"github.com/spf13/cobra"
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/token"
 )
 
 func AddCommands(c *cobra.Command) {
@@ -14,6 +17,12 @@ func AddCommands(c *cobra.Command) {
 			NewAWS(),
 			NewAzure(),
 			NewGCP(),
+			NewDigitalOcean(),
+			NewLinode(),
+			NewKubernetes(),
+			NewVault(),
+			NewLocal(),
+			NewProfile(),
 		}
 	)
 
@@ -24,3 +33,10 @@ func AddCommands(c *cobra.Command) {
 		c.AddCommand(cs[i])
 	}
 }
+
+// warnStale warns if the given token is served after failing to refresh.
+func warnStale(tk *token.Token) {
+	if tk.Stale() {
+		klog.Warning("serving a stale token as failed to refresh, which is still valid but expires soon")
+	}
+}
@@ -21,6 +21,8 @@ func NewAWS() *cobra.Command {
 				return err
 			}
 
+			warnStale(tk)
+
 			bs, err := tk.ToKubeClientExecCredentialJSON()
 			if err != nil {
 				return fmt.Errorf("error converting token to kube client exec credential json: %w", err)
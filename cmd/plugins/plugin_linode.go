@@ -0,0 +1,39 @@
+package plugins
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/seal-io/kubecia/pkg/plugins/linode"
+)
+
+func NewLinode() *cobra.Command {
+	cli := linode.Provider.NewClient()
+
+	c := &cobra.Command{
+		Use:          "linode",
+		Short:        "Get Linode Kubernetes Engine credential.",
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			tk, err := cli.GetToken(c.Context())
+			if err != nil {
+				return err
+			}
+
+			warnStale(tk)
+
+			bs, err := tk.ToKubeClientExecCredentialJSON()
+			if err != nil {
+				return fmt.Errorf("error converting token to kube client exec credential json: %w", err)
+			}
+
+			c.Print(string(bs))
+			return nil
+		},
+	}
+
+	cli.AddFlags(c.Flags())
+
+	return c
+}
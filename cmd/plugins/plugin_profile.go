@@ -0,0 +1,39 @@
+package plugins
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/seal-io/kubecia/pkg/plugins/profile"
+)
+
+func NewProfile() *cobra.Command {
+	var cli profile.Client
+
+	c := &cobra.Command{
+		Use:          "profile",
+		Short:        "Get credential of the central service profile.",
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			tk, err := cli.GetToken(c.Context())
+			if err != nil {
+				return err
+			}
+
+			warnStale(tk)
+
+			bs, err := tk.ToKubeClientExecCredentialJSON()
+			if err != nil {
+				return fmt.Errorf("error converting token to kube client exec credential json: %w", err)
+			}
+
+			c.Print(string(bs))
+			return nil
+		},
+	}
+
+	cli.AddFlags(c.Flags())
+
+	return c
+}
@@ -12,6 +12,7 @@ import (
 	"k8s.io/utils/set"
 
 	"github.com/seal-io/kubecia/cmd/apis"
+	"github.com/seal-io/kubecia/cmd/caches"
 	"github.com/seal-io/kubecia/cmd/plugins"
 	"github.com/seal-io/kubecia/pkg/signal"
 	"github.com/seal-io/kubecia/pkg/version"
@@ -58,6 +59,7 @@ func main() {
 	// Add Commands.
 	plugins.AddCommands(rc)
 	apis.AddCommands(rc)
+	caches.AddCommands(rc)
 
 	// Retrieve arguments from environment variables.
 	retrieveArguments(rc)
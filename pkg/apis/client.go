@@ -2,12 +2,61 @@ package apis
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
 	"time"
 )
 
-func Client(sock string) *http.Client {
+const (
+	// EnvTLSCertFile is the environment variable of the client certificate file,
+	// which is presented to the https central service requiring the client certificate.
+	EnvTLSCertFile = "KUBECIA_TLS_CERT_FILE"
+	// EnvTLSPrivateKeyFile is the environment variable of the client private key file.
+	EnvTLSPrivateKeyFile = "KUBECIA_TLS_PRIVATE_KEY_FILE"
+	// EnvTLSCAFile is the environment variable of the CA bundle to verify the https central service,
+	// default is the system roots.
+	EnvTLSCAFile = "KUBECIA_TLS_CA_FILE"
+)
+
+// Client returns the HTTP client to request the central service of the given target,
+// which is a socket path, a "unix:///path" URL or an "http(s)://host:port" URL.
+func Client(target string) *http.Client {
+	if u, ok := parseTargetURL(target); ok {
+		var next http.RoundTripper
+
+		tc, err := clientTLSConfig()
+		if err != nil {
+			next = errorTransport{err: err}
+		} else {
+			next = &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				DialContext: (&net.Dialer{
+					Timeout: 3 * time.Second,
+				}).DialContext,
+				TLSClientConfig:     tc,
+				TLSHandshakeTimeout: 3 * time.Second,
+			}
+		}
+
+		return &http.Client{
+			Transport: &targetTransport{
+				base: u,
+				next: next,
+			},
+		}
+	}
+
+	sock := strings.TrimPrefix(target, "unix://")
+
 	return &http.Client{
 		Transport: &http.Transport{
 			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
@@ -16,3 +65,109 @@ func Client(sock string) *http.Client {
 		},
 	}
 }
+
+// Served returns true if the central service of the given target may be serving,
+// which is always true for the http(s) URL, and checks the socket file otherwise.
+func Served(target string) bool {
+	if _, ok := parseTargetURL(target); ok {
+		return true
+	}
+
+	si, err := os.Stat(strings.TrimPrefix(target, "unix://"))
+
+	return err == nil && si.Mode()&os.ModeSocket != 0
+}
+
+func parseTargetURL(target string) (*url.URL, bool) {
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		return nil, false
+	}
+
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return nil, false
+	}
+
+	return u, true
+}
+
+// clientTLSConfig returns the tls.Config configured by the environment variables.
+func clientTLSConfig() (*tls.Config, error) {
+	c := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if f := os.Getenv(EnvTLSCAFile); f != "" {
+		bs, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("error reading tls ca: %w", err)
+		}
+
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(bs) {
+			return nil, fmt.Errorf("error loading tls ca: no certificates found in %s", f)
+		}
+	}
+
+	cf, kf := os.Getenv(EnvTLSCertFile), os.Getenv(EnvTLSPrivateKeyFile)
+
+	switch {
+	case cf != "" && kf != "":
+		cert, err := tls.LoadX509KeyPair(cf, kf)
+		if err != nil {
+			return nil, fmt.Errorf("error loading tls certificate: %w", err)
+		}
+
+		c.Certificates = []tls.Certificate{cert}
+	case cf != "" || kf != "":
+		return nil, fmt.Errorf("invalid tls config: both %s and %s are required", EnvTLSCertFile, EnvTLSPrivateKeyFile)
+	}
+
+	return c, nil
+}
+
+// errorTransport fails all requests with the given error.
+type errorTransport struct {
+	err error
+}
+
+func (t errorTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
+
+// targetTransport redirects the request routed by Route to the target URL.
+type targetTransport struct {
+	base *url.URL
+	next http.RoundTripper
+}
+
+func (t *targetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.URL.Scheme = t.base.Scheme
+	r.URL.Host = t.base.Host
+	r.URL.Path = path.Join("/", t.base.Path, req.URL.Path)
+	r.Host = ""
+
+	return t.next.RoundTrip(r)
+}
+
+// ResponseError returns the error of the given unsuccessful response,
+// which carries the message and the Retry-After signal if present.
+func ResponseError(resp *http.Response) error {
+	bs, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+
+	msg := strings.TrimSpace(string(bs))
+	if msg == "" {
+		return fmt.Errorf("error response from remote: %s", resp.Status)
+	}
+
+	if ra := resp.Header.Get("Retry-After"); ra != "" {
+		if _, err := strconv.Atoi(ra); err == nil {
+			ra += "s"
+		}
+
+		return fmt.Errorf("error response from remote: %s, retry after %s: %s", resp.Status, ra, msg)
+	}
+
+	return fmt.Errorf("error response from remote: %s: %s", resp.Status, msg)
+}
@@ -0,0 +1,110 @@
+package apis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// recordTransport records the request and replies no content.
+type recordTransport struct {
+	req *http.Request
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.req = req
+	return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody, Request: req}, nil
+}
+
+func TestTargetTransport(t *testing.T) {
+	cases := []struct {
+		base     string
+		route    string
+		expected string
+	}{
+		{
+			base:     "https://kubecia.example.com:8443",
+			route:    Route("aws", "token"),
+			expected: "https://kubecia.example.com:8443/aws/token",
+		},
+		{
+			base:     "https://example.com/kubecia/",
+			route:    Route("aws", "token") + "?region=us-east-1",
+			expected: "https://example.com/kubecia/aws/token?region=us-east-1",
+		},
+		{
+			base:     "http://127.0.0.1:8080",
+			route:    Route("gcp", "token"),
+			expected: "http://127.0.0.1:8080/gcp/token",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.expected, func(t *testing.T) {
+			base, err := url.Parse(tc.base)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var (
+				next = &recordTransport{}
+				tt   = &targetTransport{base: base, next: next}
+			)
+
+			req, err := http.NewRequest(http.MethodGet, tc.route, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, err = tt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := next.req.URL.String(); got != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, got)
+			}
+
+			if next.req.Host != "" {
+				t.Errorf("expected blank host header, got %s", next.req.Host)
+			}
+
+			// The given request is not modified.
+			if req.URL.String() != tc.route {
+				t.Errorf("expected unmodified %s, got %s", tc.route, req.URL)
+			}
+		})
+	}
+}
+
+func TestClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/aws/token" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	resp, err := Client(srv.URL).Get(Route("aws", "token"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", resp.StatusCode)
+	}
+
+	// Fail the requests if the tls config is invalid.
+	t.Setenv(EnvTLSCertFile, "tls.crt")
+
+	_, err = Client(srv.URL).Get(Route("aws", "token"))
+	if err == nil {
+		t.Error("expected error of the incomplete tls config, got nil")
+	}
+}
@@ -8,10 +8,12 @@ func RoutePrefix(namespace string) string {
 	return path.Join("/", namespace) + "/"
 }
 
+// Route returns the URL of the given namespace and paths,
+// the host is ignored as the request is dialed to the socket.
 func Route(namespace string, paths ...string) string {
-	ps := make([]string, 0, len(paths)+3)
-	ps = append(ps, "http", ".", namespace)
+	ps := make([]string, 0, len(paths)+2)
+	ps = append(ps, "/", namespace)
 	ps = append(ps, paths...)
 
-	return path.Join(ps...)
+	return "http://kubecia" + path.Join(ps...)
 }
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/apis"
+	"github.com/seal-io/kubecia/pkg/cache"
+	"github.com/seal-io/kubecia/pkg/json"
+	"github.com/seal-io/kubecia/pkg/token"
+)
+
+// AdminNamespace is the route namespace of the admin APIs.
+const AdminNamespace = "admin"
+
+// ServeAdmin serves the admin APIs of the cache.
+func ServeAdmin(ctx context.Context, mux *http.ServeMux, opts ServeOptions) error {
+	klog.Infof("serving %[1]s: /%[1]s/cache[/inspect|/purge|/prune|/stats]\n", AdminNamespace)
+
+	rp := apis.RoutePrefix(AdminNamespace)
+	hd := http.StripPrefix(rp, &adminServer{
+		ServeOptions: opts,
+		Logger:       klog.LoggerWithName(klog.Background(), AdminNamespace),
+	})
+
+	mux.Handle(rp, hd)
+
+	return nil
+}
+
+type adminServer struct {
+	ServeOptions
+
+	Logger klog.Logger
+}
+
+func (s *adminServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.Authorizer.Authorize(w, r, Attributes{Provider: AdminNamespace}) {
+		return
+	}
+
+	var (
+		q   = r.URL.Query()
+		ctx = r.Context()
+		v   any
+		err error
+	)
+
+	switch p := strings.TrimSuffix(r.URL.Path, "/"); {
+	// GET cache?pattern={pattern}.
+	case p == "cache" && r.Method == http.MethodGet:
+		v, err = token.ListEntries(ctx, s.Cache, q.Get("pattern"))
+	// DELETE cache?pattern={pattern}.
+	case p == "cache" && r.Method == http.MethodDelete:
+		v, err = token.RemoveEntries(ctx, s.Cache, q.Get("pattern"))
+	// GET cache/inspect?key={key}.
+	case p == "cache/inspect" && r.Method == http.MethodGet:
+		v, err = token.InspectEntry(ctx, s.Cache, q.Get("key"))
+	// POST cache/purge.
+	case p == "cache/purge" && r.Method == http.MethodPost:
+		v, err = token.RemoveEntries(ctx, s.Cache, "*")
+	// POST cache/prune.
+	case p == "cache/prune" && r.Method == http.MethodPost:
+		v, err = token.PruneEntries(ctx, s.Cache)
+	// GET cache/stats.
+	case p == "cache/stats" && r.Method == http.MethodGet:
+		v, err = token.GetStats(ctx, s.Cache)
+	case p == "cache" || strings.HasPrefix(p, "cache/"):
+		c := http.StatusMethodNotAllowed
+		http.Error(w, http.StatusText(c), c)
+
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		c := http.StatusInternalServerError
+
+		switch {
+		case errors.Is(err, cache.ErrEntryNotFound):
+			c = http.StatusNotFound
+		case errors.Is(err, token.ErrNotSupported):
+			c = http.StatusNotImplemented
+		default:
+			s.Logger.Error(err, "error operating cache", "path", r.URL.Path)
+		}
+
+		http.Error(w, err.Error(), c)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(json.MustMarshal(v))
+}
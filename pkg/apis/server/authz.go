@@ -0,0 +1,240 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// AnonymousSubject is the subject of the request without a verified client certificate or peer credentials,
+	// e.g. the request from the tcp listener.
+	AnonymousSubject = "system:anonymous"
+	// SubjectUIDPrefix prefixes the peer UID subject of the request from the unix socket.
+	SubjectUIDPrefix = "uid:"
+	// SubjectGIDPrefix prefixes the peer GID subject of the request from the unix socket.
+	SubjectGIDPrefix = "gid:"
+)
+
+type (
+	// AuthorizationPolicy holds the rules to authorize the requests,
+	// a request is allowed if any rule matches.
+	AuthorizationPolicy struct {
+		Rules []AuthorizationRule `json:"rules"`
+	}
+
+	// AuthorizationRule describes the requests allowed to the subjects,
+	// each field accepts the wildcard patterns, where "*" matches any characters,
+	// an empty field matches all.
+	AuthorizationRule struct {
+		// Subjects are the identities of the client certificate,
+		// which matches the common name, DNS, email or URI SANs,
+		// or the peer credentials of the unix socket, in the form of "uid:{uid}" and "gid:{gid}",
+		// or AnonymousSubject for the others.
+		Subjects []string `json:"subjects"`
+		// Providers are the requested providers, e.g. "aws", or "admin" for the admin APIs.
+		Providers []string `json:"providers,omitempty"`
+		// Clusters are the requested clusters, e.g. "{region}/{cluster}" of aws and gcp,
+		// the resource of azure, or "{engine}/{mount}" of vault.
+		Clusters []string `json:"clusters,omitempty"`
+		// Tenants are the requested tenants, e.g. the tenant of azure,
+		// the namespace of kubernetes, or the namespace of vault.
+		Tenants []string `json:"tenants,omitempty"`
+		// Roles are the requested roles, e.g. the assume role ARN of aws,
+		// the service account of kubernetes, the role of vault, or the subject of local.
+		Roles []string `json:"roles,omitempty"`
+	}
+
+	// Attributes describes the request to authorize.
+	Attributes struct {
+		Provider string
+		Cluster  string
+		Tenant   string
+		Role     string
+	}
+)
+
+// Authorizer authorizes the requests with the AuthorizationPolicy.
+type Authorizer struct {
+	logger klog.Logger
+	rules  []authorizationRule
+}
+
+type authorizationRule struct {
+	subjects, providers, clusters, tenants, roles []*regexp.Regexp
+}
+
+// NewAuthorizer returns an Authorizer with the policy of the given file,
+// returns nil if the file is blank, which allows all requests.
+func NewAuthorizer(policyFile string) (*Authorizer, error) {
+	if policyFile == "" {
+		return nil, nil
+	}
+
+	bs, err := os.ReadFile(policyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading authorization policy: %w", err)
+	}
+
+	var p AuthorizationPolicy
+	if err = yaml.UnmarshalStrict(bs, &p); err != nil {
+		return nil, fmt.Errorf("error decoding authorization policy: %w", err)
+	}
+
+	a := &Authorizer{
+		logger: klog.LoggerWithName(klog.Background(), "authorizer"),
+		rules:  make([]authorizationRule, 0, len(p.Rules)),
+	}
+
+	for i, r := range p.Rules {
+		if len(r.Subjects) == 0 {
+			return nil, fmt.Errorf("invalid authorization rule %d: blank subjects", i)
+		}
+
+		a.rules = append(a.rules, authorizationRule{
+			subjects:  compileWildcards(r.Subjects),
+			providers: compileWildcards(r.Providers),
+			clusters:  compileWildcards(r.Clusters),
+			tenants:   compileWildcards(r.Tenants),
+			roles:     compileWildcards(r.Roles),
+		})
+	}
+
+	return a, nil
+}
+
+// Authorize returns true if the given request is allowed,
+// otherwise, replies 403 and returns false.
+func (a *Authorizer) Authorize(w http.ResponseWriter, r *http.Request, attrs Attributes) bool {
+	if a == nil {
+		return true
+	}
+
+	err := a.authorize(RequestSubjects(r), attrs)
+	if err == nil {
+		return true
+	}
+
+	a.logger.Info("denied", "reason", err.Error(),
+		"provider", attrs.Provider, "cluster", attrs.Cluster, "tenant", attrs.Tenant, "role", attrs.Role)
+
+	c := http.StatusForbidden
+	http.Error(w, http.StatusText(c), c)
+
+	return false
+}
+
+func (a *Authorizer) authorize(subjects []string, attrs Attributes) error {
+	for _, r := range a.rules {
+		if !matchAny(r.subjects, subjects...) {
+			continue
+		}
+
+		if matchAll(r.providers, attrs.Provider) &&
+			matchAll(r.clusters, attrs.Cluster) &&
+			matchAll(r.tenants, attrs.Tenant) &&
+			matchAll(r.roles, attrs.Role) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no rule allows %s", strings.Join(subjects, ","))
+}
+
+// RequestSubjects returns the identities of the verified client certificate
+// or the peer credentials of the given request, or AnonymousSubject if not found.
+func RequestSubjects(r *http.Request) []string {
+	if p, ok := PeerCredentialsFromContext(r.Context()); ok {
+		return p.Subjects()
+	}
+
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.PeerCertificates) == 0 {
+		return []string{AnonymousSubject}
+	}
+
+	c := r.TLS.PeerCertificates[0]
+
+	ss := make([]string, 0, 1+len(c.DNSNames)+len(c.EmailAddresses)+len(c.URIs))
+	if c.Subject.CommonName != "" {
+		ss = append(ss, c.Subject.CommonName)
+	}
+
+	ss = append(ss, c.DNSNames...)
+	ss = append(ss, c.EmailAddresses...)
+
+	for _, u := range c.URIs {
+		ss = append(ss, u.String())
+	}
+
+	if len(ss) == 0 {
+		return []string{AnonymousSubject}
+	}
+
+	return ss
+}
+
+// SubjectMatcher matches the request subjects with the wildcard patterns.
+type SubjectMatcher []*regexp.Regexp
+
+// NewSubjectMatcher returns a SubjectMatcher of the given patterns,
+// where "*" matches any characters.
+func NewSubjectMatcher(patterns []string) SubjectMatcher {
+	return compileWildcards(patterns)
+}
+
+// Match returns true if any subject of the given request matches.
+func (m SubjectMatcher) Match(r *http.Request) bool {
+	return matchAny(m, RequestSubjects(r)...)
+}
+
+// Wildcards matches the values with the wildcard patterns.
+type Wildcards []*regexp.Regexp
+
+// NewWildcards returns the Wildcards of the given patterns,
+// where "*" matches any characters.
+func NewWildcards(patterns []string) Wildcards {
+	return compileWildcards(patterns)
+}
+
+// Match returns true if any of the given values matches any pattern.
+func (w Wildcards) Match(vs ...string) bool {
+	return matchAny(w, vs...)
+}
+
+// compileWildcards compiles the given wildcard patterns,
+// where "*" matches any characters.
+func compileWildcards(ps []string) []*regexp.Regexp {
+	rs := make([]*regexp.Regexp, 0, len(ps))
+
+	for _, p := range ps {
+		s := regexp.QuoteMeta(p)
+		s = strings.ReplaceAll(s, `\*`, `.*`)
+		rs = append(rs, regexp.MustCompile("^"+s+"$"))
+	}
+
+	return rs
+}
+
+// matchAny returns true if any of the given values matches any of the given patterns.
+func matchAny(rs []*regexp.Regexp, vs ...string) bool {
+	for _, r := range rs {
+		for _, v := range vs {
+			if r.MatchString(v) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchAll returns true if the given patterns are empty,
+// or the given value matches any of them.
+func matchAll(rs []*regexp.Regexp, v string) bool {
+	return len(rs) == 0 || matchAny(rs, v)
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/token"
+)
+
+// ErrorToken replies the error of getting token,
+// a backoff error is replied with 503 and the Retry-After header.
+func ErrorToken(w http.ResponseWriter, logger klog.Logger, err error) {
+	var be *token.BackoffError
+	if !errors.As(err, &be) {
+		logger.Error(err, "error getting token")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	// Do not flood the log during backoff.
+	if be.Cached {
+		logger.V(4).Info("backing off", "failures", be.Failures, "retryAt", be.RetryAt)
+	} else {
+		logger.Error(err, "error getting token")
+	}
+
+	ra := int(math.Ceil(be.RetryAfter().Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(ra))
+	http.Error(w, err.Error(), http.StatusServiceUnavailable)
+}
+
+// RefuseEnv replies 400 and returns true if any of the given credentials is of the form "$VAR",
+// unless the ServeOptions allow expanding the environment of the service.
+func (o ServeOptions) RefuseEnv(w http.ResponseWriter, creds ...string) bool {
+	if o.ExpandEnv {
+		return false
+	}
+
+	for i := range creds {
+		if strings.HasPrefix(creds[i], "$") {
+			http.Error(w, "invalid options: expanding credentials from environment is disabled, "+
+				"please start the service with --expand-env, or request the profiles instead", http.StatusBadRequest)
+
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,302 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// TLSConfig holds the configuration of the https listeners.
+type TLSConfig struct {
+	// CertFile is the PEM-encoded certificate file, including the intermediates.
+	CertFile string
+	// KeyFile is the PEM-encoded private key file.
+	KeyFile string
+	// ClientCAFile is the PEM-encoded CA bundle to verify the client certificates,
+	// the client certificate is required if specified.
+	ClientCAFile string
+	// ReloadInterval indicates the interval to check the rotation of the files,
+	// default is 10 seconds.
+	ReloadInterval time.Duration
+}
+
+func (c *TLSConfig) Default() {
+	if c.ReloadInterval == 0 {
+		c.ReloadInterval = 10 * time.Second
+	}
+}
+
+func (c *TLSConfig) Validate() error {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return errors.New("invalid tls config: both certificate and private key files are required")
+	}
+
+	if c.ReloadInterval < 0 {
+		return errors.New("invalid tls reload interval: negative")
+	}
+
+	return nil
+}
+
+// SocketConfig holds the configuration of the unix socket listeners.
+type SocketConfig struct {
+	// Mode is the octal permission of the socket,
+	// default is "0660".
+	Mode string
+	// Owner is the user name or UID to own the socket,
+	// default is the running user.
+	Owner string
+	// Group is the group name or GID to own the socket,
+	// default is the group of the running user.
+	Group string
+}
+
+func (c *SocketConfig) Default() {
+	if c.Mode == "" {
+		c.Mode = "0660"
+	}
+}
+
+// socketOptions is the resolved SocketConfig,
+// the UID and GID are -1 if not changed.
+type socketOptions struct {
+	mode os.FileMode
+	uid  int
+	gid  int
+}
+
+// resolve parses the mode, and looks up the owner and group.
+func (c *SocketConfig) resolve() (socketOptions, error) {
+	o := socketOptions{uid: -1, gid: -1}
+
+	m, err := strconv.ParseUint(c.Mode, 8, 32)
+	if err != nil || m > 0o777 {
+		return o, fmt.Errorf("invalid socket mode %q: must be octal permission bits", c.Mode)
+	}
+
+	o.mode = os.FileMode(m)
+
+	if c.Owner != "" {
+		o.uid, err = strconv.Atoi(c.Owner)
+		if err != nil {
+			u, err := user.Lookup(c.Owner)
+			if err != nil {
+				return o, fmt.Errorf("invalid socket owner %q: %w", c.Owner, err)
+			}
+
+			o.uid, _ = strconv.Atoi(u.Uid)
+		}
+	}
+
+	if c.Group != "" {
+		o.gid, err = strconv.Atoi(c.Group)
+		if err != nil {
+			g, err := user.LookupGroup(c.Group)
+			if err != nil {
+				return o, fmt.Errorf("invalid socket group %q: %w", c.Group, err)
+			}
+
+			o.gid, _ = strconv.Atoi(g.Gid)
+		}
+	}
+
+	return o, nil
+}
+
+// listenAddress is a parsed address of the --listen flag.
+type listenAddress struct {
+	scheme  string
+	address string
+}
+
+func (a listenAddress) String() string {
+	return a.scheme + "://" + a.address
+}
+
+// parseListenAddress parses the given address,
+// which is in the form of "unix:///path", "tcp://host:port" or "https://host:port".
+func parseListenAddress(s string) (listenAddress, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return listenAddress{}, fmt.Errorf("invalid listen address %q: %w", s, err)
+	}
+
+	a := listenAddress{scheme: u.Scheme}
+
+	switch u.Scheme {
+	case "unix":
+		a.address = u.Host + u.Path
+	case "tcp", "https":
+		a.address = u.Host
+		if u.Path != "" && u.Path != "/" {
+			return listenAddress{}, fmt.Errorf("invalid listen address %q: path is not supported", s)
+		}
+	default:
+		return listenAddress{}, fmt.Errorf("invalid listen address %q: unknown scheme, select from unix, tcp and https", s)
+	}
+
+	if a.address == "" {
+		return listenAddress{}, fmt.Errorf("invalid listen address %q: blank", s)
+	}
+
+	return a, nil
+}
+
+// newListener creates the listener of the given address,
+// the certificate is required by the https listener.
+func newListener(a listenAddress, cert *certificateReloader, sock socketOptions) (net.Listener, error) {
+	switch a.scheme {
+	case "unix":
+		return newUnixListener(a.address, sock)
+	case "https":
+		if cert == nil {
+			return nil, errors.New("error creating https listener: tls certificate and private key are required")
+		}
+
+		ls, err := net.Listen("tcp", a.address)
+		if err != nil {
+			return nil, fmt.Errorf("error creating tcp listener: %w", err)
+		}
+
+		return tls.NewListener(ls, cert.TLSConfig()), nil
+	}
+
+	ls, err := net.Listen("tcp", a.address)
+	if err != nil {
+		return nil, fmt.Errorf("error creating tcp listener: %w", err)
+	}
+
+	return ls, nil
+}
+
+// certificateReloader serves the certificate and the client CA of the given files,
+// and reloads them once rotated.
+type certificateReloader struct {
+	logger   klog.Logger
+	cfg      TLSConfig
+	cert     atomic.Pointer[tls.Certificate]
+	clientCA atomic.Pointer[x509.CertPool]
+	modTime  atomic.Pointer[[3]time.Time]
+}
+
+func newCertificateReloader(cfg TLSConfig) (*certificateReloader, error) {
+	cfg.Default()
+
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &certificateReloader{
+		logger: klog.LoggerWithName(klog.Background(), "tls"),
+		cfg:    cfg,
+	}
+
+	_, err = r.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Start runs the reloading looping until the given context is done.
+func (r *certificateReloader) Start(ctx context.Context) {
+	_ = wait.PollUntilContextCancel(ctx, r.cfg.ReloadInterval, false, func(ctx context.Context) (bool, error) {
+		reloaded, err := r.reload()
+		if err != nil {
+			// Keep serving the previous certificate,
+			// the files may be in the middle of rotating.
+			r.logger.Error(err, "error reloading certificate")
+		} else if reloaded {
+			r.logger.Info("reloaded certificate", "cert", r.cfg.CertFile)
+		}
+
+		return false, nil
+	})
+}
+
+// reload loads the certificate and the client CA if the files are modified since last loading.
+func (r *certificateReloader) reload() (bool, error) {
+	var mt [3]time.Time
+
+	for i, f := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCAFile} {
+		if f == "" {
+			continue
+		}
+
+		fi, err := os.Stat(f)
+		if err != nil {
+			return false, fmt.Errorf("error stating %s: %w", f, err)
+		}
+
+		mt[i] = fi.ModTime()
+	}
+
+	if p := r.modTime.Load(); p != nil && *p == mt {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return false, fmt.Errorf("error loading certificate: %w", err)
+	}
+
+	if r.cfg.ClientCAFile != "" {
+		bs, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return false, fmt.Errorf("error reading client ca: %w", err)
+		}
+
+		p := x509.NewCertPool()
+		if !p.AppendCertsFromPEM(bs) {
+			return false, fmt.Errorf("error loading client ca: no certificates found in %s", r.cfg.ClientCAFile)
+		}
+
+		r.clientCA.Store(p)
+	}
+
+	r.cert.Store(&cert)
+	r.modTime.Store(&mt)
+
+	return true, nil
+}
+
+// TLSConfig returns the tls.Config serving the reloaded certificate,
+// and requiring the client certificate if the client CA specified.
+func (r *certificateReloader) TLSConfig() *tls.Config {
+	c := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+
+	if r.cfg.ClientCAFile != "" {
+		c.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return &tls.Config{
+				MinVersion:     tls.VersionTLS12,
+				GetCertificate: r.GetCertificate,
+				ClientAuth:     tls.RequireAndVerifyClientCert,
+				ClientCAs:      r.clientCA.Load(),
+			}, nil
+		}
+	}
+
+	return c
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseListenAddress(t *testing.T) {
+	cases := []struct {
+		given    string
+		expected string
+		valid    bool
+	}{
+		{given: "unix:///var/run/kubecia.sock", expected: "unix:///var/run/kubecia.sock", valid: true},
+		{given: "unix://kubecia.sock", expected: "unix://kubecia.sock", valid: true},
+		{given: "tcp://127.0.0.1:8080", expected: "tcp://127.0.0.1:8080", valid: true},
+		{given: "https://:8443", expected: "https://:8443", valid: true},
+		{given: "https://:8443/", expected: "https://:8443", valid: true},
+		{given: "https://:8443/path"},
+		{given: "http://:8080"},
+		{given: "/var/run/kubecia.sock"},
+		{given: "tcp://"},
+		{given: "unix://"},
+		{given: "://"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.given, func(t *testing.T) {
+			a, err := parseListenAddress(tc.given)
+			if tc.valid != (err == nil) {
+				t.Fatalf("expected valid %v, got error %v", tc.valid, err)
+			}
+
+			if tc.valid && a.String() != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, a)
+			}
+		})
+	}
+}
+
+// writeCertificate writes a self-signed certificate of the given common name,
+// and sets the modified time of the files to the given time.
+func writeCertificate(t *testing.T, certFile, keyFile, cn string, mt time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+
+	kder, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("error marshaling key: %v", err)
+	}
+
+	files := map[string][]byte{
+		certFile: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyFile:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}),
+	}
+
+	for f, bs := range files {
+		if err = os.WriteFile(f, bs, 0o600); err != nil {
+			t.Fatalf("error writing %s: %v", f, err)
+		}
+
+		if err = os.Chtimes(f, mt, mt); err != nil {
+			t.Fatalf("error touching %s: %v", f, err)
+		}
+	}
+}
+
+func TestCertificateReloader(t *testing.T) {
+	var (
+		dir      = t.TempDir()
+		certFile = filepath.Join(dir, "tls.crt")
+		keyFile  = filepath.Join(dir, "tls.key")
+		now      = time.Now()
+	)
+
+	writeCertificate(t, certFile, keyFile, "first", now.Add(-time.Minute))
+
+	r, err := newCertificateReloader(TLSConfig{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	commonName := func() string {
+		c, err := r.GetCertificate(nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		x, err := x509.ParseCertificate(c.Certificate[0])
+		if err != nil {
+			t.Fatalf("error parsing certificate: %v", err)
+		}
+
+		return x.Subject.CommonName
+	}
+
+	if cn := commonName(); cn != "first" {
+		t.Errorf("expected first, got %s", cn)
+	}
+
+	// Skip the unmodified files.
+	reloaded, err := r.reload()
+	if err != nil || reloaded {
+		t.Errorf("expected not reloaded, got %v with error %v", reloaded, err)
+	}
+
+	// Reload the rotated files.
+	writeCertificate(t, certFile, keyFile, "second", now)
+
+	reloaded, err = r.reload()
+	if err != nil || !reloaded {
+		t.Errorf("expected reloaded, got %v with error %v", reloaded, err)
+	}
+
+	if cn := commonName(); cn != "second" {
+		t.Errorf("expected second, got %s", cn)
+	}
+
+	// Keep the previous certificate if the rotating files are broken.
+	if err = os.WriteFile(keyFile, []byte("broken"), 0o600); err != nil {
+		t.Fatalf("error writing %s: %v", keyFile, err)
+	}
+
+	if err = os.Chtimes(keyFile, now.Add(time.Minute), now.Add(time.Minute)); err != nil {
+		t.Fatalf("error touching %s: %v", keyFile, err)
+	}
+
+	if _, err = r.reload(); err == nil {
+		t.Error("expected error of the broken private key, got nil")
+	}
+
+	if cn := commonName(); cn != "second" {
+		t.Errorf("expected second, got %s", cn)
+	}
+}
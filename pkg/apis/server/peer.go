@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/token"
+)
+
+// PeerCredentials is the identity of the process connected via the unix socket.
+type PeerCredentials struct {
+	UID int
+	GID int
+	PID int
+}
+
+// Subjects returns the identities of the peer used by the AuthorizationPolicy,
+// in the form of "uid:{uid}" and "gid:{gid}".
+func (p PeerCredentials) Subjects() []string {
+	return []string{
+		SubjectUIDPrefix + strconv.Itoa(p.UID),
+		SubjectGIDPrefix + strconv.Itoa(p.GID),
+	}
+}
+
+type peerCredentialsContextKey struct{}
+
+// PeerCredentialsFromContext returns the PeerCredentials of the connection serving the given context,
+// returns false if the connection is not a unix socket or the platform does not support.
+func PeerCredentialsFromContext(ctx context.Context) (PeerCredentials, bool) {
+	p, ok := ctx.Value(peerCredentialsContextKey{}).(PeerCredentials)
+	return p, ok
+}
+
+// withPeerCredentials implements http.Server.ConnContext,
+// which reads the peer credentials of the accepted unix connection.
+func withPeerCredentials(ctx context.Context, c net.Conn) context.Context {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return ctx
+	}
+
+	p, err := readPeerCredentials(uc)
+	if err != nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, peerCredentialsContextKey{}, p)
+}
+
+// LogIssued logs the issued token of the given key with the identity of the requester.
+func LogIssued(logger klog.Logger, r *http.Request, key string, tk *token.Token) {
+	exp := tk.Expiration
+	if exp.IsZero() {
+		exp = tk.ClientCertificateExpiration()
+	}
+
+	kvs := []any{"key", key, "subjects", RequestSubjects(r), "expiration", exp.Format(time.RFC3339), "stale", tk.Stale()}
+
+	if p, ok := PeerCredentialsFromContext(r.Context()); ok {
+		kvs = append(kvs, "pid", p.PID)
+	}
+
+	logger.Info("issued token", kvs...)
+}
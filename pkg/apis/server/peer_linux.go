@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net"
+	"syscall"
+)
+
+// readPeerCredentials reads the SO_PEERCRED of the given connection.
+func readPeerCredentials(c *net.UnixConn) (PeerCredentials, error) {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return PeerCredentials{}, err
+	}
+
+	var (
+		uc   *syscall.Ucred
+		uErr error
+	)
+
+	err = rc.Control(func(fd uintptr) {
+		uc, uErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return PeerCredentials{}, err
+	}
+
+	if uErr != nil {
+		return PeerCredentials{}, uErr
+	}
+
+	return PeerCredentials{
+		UID: int(uc.Uid),
+		GID: int(uc.Gid),
+		PID: int(uc.Pid),
+	}, nil
+}
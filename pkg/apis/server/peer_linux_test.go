@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// socketpair returns the connected unix connections.
+func socketpair(t *testing.T) (*net.UnixConn, *net.UnixConn) {
+	t.Helper()
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("error creating socketpair: %v", err)
+	}
+
+	ucs := make([]*net.UnixConn, 0, 2)
+
+	for _, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socketpair")
+
+		c, err := net.FileConn(f)
+
+		_ = f.Close()
+
+		if err != nil {
+			t.Fatalf("error creating connection: %v", err)
+		}
+
+		t.Cleanup(func() { _ = c.Close() })
+
+		ucs = append(ucs, c.(*net.UnixConn))
+	}
+
+	return ucs[0], ucs[1]
+}
+
+func TestReadPeerCredentials(t *testing.T) {
+	c, _ := socketpair(t)
+
+	p, err := readPeerCredentials(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := PeerCredentials{UID: os.Getuid(), GID: os.Getgid(), PID: os.Getpid()}
+	if p != expected {
+		t.Errorf("expected %+v, got %+v", expected, p)
+	}
+
+	ctx := withPeerCredentials(context.Background(), c)
+
+	p, ok := PeerCredentialsFromContext(ctx)
+	if !ok || p != expected {
+		t.Errorf("expected %+v from context, got %+v", expected, p)
+	}
+}
+
+func TestWithPeerCredentials_tcp(t *testing.T) {
+	ls, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+
+	t.Cleanup(func() { _ = ls.Close() })
+
+	c, err := net.Dial("tcp", ls.Addr().String())
+	if err != nil {
+		t.Fatalf("error dialing: %v", err)
+	}
+
+	t.Cleanup(func() { _ = c.Close() })
+
+	if _, ok := PeerCredentialsFromContext(withPeerCredentials(context.Background(), c)); ok {
+		t.Error("expected no peer credentials of tcp connection")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package server
+
+import (
+	"errors"
+	"net"
+)
+
+// readPeerCredentials is not supported on this platform.
+func readPeerCredentials(*net.UnixConn) (PeerCredentials, error) {
+	return PeerCredentials{}, errors.New("peer credentials are not supported")
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/token"
+)
+
+// RefreshConfig holds the configuration of the refresh-ahead scheduler.
+type RefreshConfig struct {
+	// Fraction indicates the elapsed fraction of the token lifetime to refresh at,
+	// zero means disabled.
+	Fraction float64
+	// IdleTimeout indicates to stop refreshing the token not requested in this period.
+	IdleTimeout time.Duration
+	// Interval indicates the scanning interval,
+	// default is 10 seconds.
+	Interval time.Duration
+}
+
+func (c *RefreshConfig) Default() {
+	if c.Interval == 0 {
+		c.Interval = 10 * time.Second
+	}
+}
+
+func (c *RefreshConfig) Validate() error {
+	if c.Fraction < 0 || c.Fraction >= 1 {
+		return errors.New("invalid refresh fraction: must be in [0, 1)")
+	}
+
+	if c.IdleTimeout < 0 {
+		return errors.New("invalid refresh idle timeout: negative")
+	}
+
+	if c.Interval < 0 {
+		return errors.New("invalid refresh interval: negative")
+	}
+
+	return nil
+}
+
+// Refresher tracks the recently requested tokens,
+// and refreshes them in the background before they expire.
+type Refresher struct {
+	logger klog.Logger
+	cfg    RefreshConfig
+
+	mu      sync.Mutex
+	entries map[string]*refreshEntry
+}
+
+type refreshEntry struct {
+	refresh     token.FetchFunc
+	refreshedAt time.Time
+	expiration  time.Time
+	requestedAt time.Time
+	refreshing  bool
+}
+
+// dueAt returns the time to refresh the entry.
+func (e *refreshEntry) dueAt(fraction float64) time.Time {
+	lifetime := e.expiration.Sub(e.refreshedAt)
+	return e.refreshedAt.Add(time.Duration(float64(lifetime) * fraction))
+}
+
+// NewRefresher returns a Refresher with the given configuration,
+// returns nil if disabled.
+func NewRefresher(cfg RefreshConfig) (*Refresher, error) {
+	cfg.Default()
+
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Fraction == 0 {
+		return nil, nil
+	}
+
+	r := &Refresher{
+		logger:  klog.LoggerWithName(klog.Background(), "refresher"),
+		cfg:     cfg,
+		entries: map[string]*refreshEntry{},
+	}
+
+	return r, nil
+}
+
+// Track records the token of the given key is requested,
+// refresh is called in the background to renew the token later.
+func (r *Refresher) Track(key string, tk *token.Token, refresh token.FetchFunc) {
+	if r == nil || tk == nil || tk.Expiration.IsZero() {
+		return
+	}
+
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[key]
+	if !ok {
+		e = &refreshEntry{}
+		r.entries[key] = e
+	}
+
+	e.refresh = refresh
+	e.requestedAt = now
+
+	// The token may come from cache,
+	// take the first observation as the start of its lifetime.
+	if !e.expiration.Equal(tk.Expiration) && !e.refreshing {
+		e.refreshedAt = now
+		e.expiration = tk.Expiration
+	}
+}
+
+// Start runs the refreshing looping until the given context is done.
+func (r *Refresher) Start(ctx context.Context) {
+	if r == nil {
+		return
+	}
+
+	_ = wait.PollUntilContextCancel(ctx, r.cfg.Interval, false, func(ctx context.Context) (bool, error) {
+		r.refreshDue(ctx)
+		return false, nil
+	})
+}
+
+func (r *Refresher) refreshDue(ctx context.Context) {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for k, e := range r.entries {
+		switch {
+		case e.refreshing:
+			continue
+		case r.cfg.IdleTimeout > 0 && now.Sub(e.requestedAt) > r.cfg.IdleTimeout:
+			r.logger.V(5).Info("idle, stop refreshing", "key", k)
+			delete(r.entries, k)
+
+			continue
+		case now.Before(e.dueAt(r.cfg.Fraction)):
+			continue
+		}
+
+		e.refreshing = true
+
+		go r.refreshOne(ctx, k, e)
+	}
+}
+
+func (r *Refresher) refreshOne(ctx context.Context, key string, e *refreshEntry) {
+	r.logger.V(5).Info("refreshing", "key", key)
+
+	tk, err := e.refresh(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e.refreshing = false
+
+	if err != nil {
+		var be *token.BackoffError
+		if errors.As(err, &be) && be.Cached {
+			r.logger.V(5).Info("backing off", "key", key, "retryAt", be.RetryAt)
+		} else {
+			r.logger.Error(err, "error refreshing token", "key", key)
+		}
+
+		// Give up if the token is expired,
+		// the next request will track it again.
+		if !time.Now().Before(e.expiration) {
+			delete(r.entries, key)
+		}
+
+		return
+	}
+
+	e.refreshedAt = time.Now()
+	e.expiration = tk.Expiration
+
+	r.logger.V(5).Info("refreshed", "key", key, "expiration", tk.Expiration)
+}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/spf13/pflag"
+	"golang.org/x/sync/errgroup"
 	"k8s.io/klog/v2"
 
 	"github.com/seal-io/kubecia/pkg/cache"
@@ -23,6 +24,15 @@ import (
 type (
 	ServeOptions struct {
 		Cache cache.Cache
+		// Refresher tracks the served tokens to refresh ahead,
+		// nil if disabled.
+		Refresher *Refresher
+		// Authorizer authorizes the requests before accessing the cache or upstream,
+		// nil if allowing all.
+		Authorizer *Authorizer
+		// ExpandEnv indicates to expand the requested credentials of the form "$VAR",
+		// which uses the credentials hosted in the environment of the service.
+		ExpandEnv bool
 	}
 
 	ServeFunc  = func(context.Context, *http.ServeMux, ServeOptions) error
@@ -30,25 +40,90 @@ type (
 
 	Server struct {
 		Socket     string
+		Listen     []string
+		UnixSocket SocketConfig
+		TLS        TLSConfig
+		PolicyFile string
+		Cache      string
+		CacheKey   string
+		Admin      bool
+		ExpandEnv  bool
+		Refresh    RefreshConfig
 		ServeFuncs ServeFuncs
 	}
 )
 
 func (s *Server) AddFlags(flags *pflag.FlagSet) {
-	flags.StringVar(&s.Socket, "socket", consts.SocketPath(), "Socket path")
+	flags.StringVar(&s.Socket, "socket", consts.SocketPath(), "Socket path, ignored if --listen specified")
+	flags.StringVar(&s.UnixSocket.Mode, "socket-mode", "0660",
+		"Octal permission of the unix sockets, e.g. 0666 to allow all local users, "+
+			"which should be restricted by --authorization-policy-file")
+	flags.StringVar(&s.UnixSocket.Owner, "socket-owner", "",
+		"User name or UID to own the unix sockets, default is the running user")
+	flags.StringVar(&s.UnixSocket.Group, "socket-group", "",
+		"Group name or GID to own the unix sockets, default is the group of the running user")
+	flags.StringSliceVar(&s.Listen, "listen", nil,
+		"Addresses to listen, repeatable, select from unix:///path, tcp://host:port and https://host:port, "+
+			"the tcp and https listeners require --authorization-policy-file, default is the --socket path")
+	flags.StringVar(&s.TLS.CertFile, "tls-cert-file", "",
+		"Certificate file of the https listeners, reloaded once rotated")
+	flags.StringVar(&s.TLS.KeyFile, "tls-private-key-file", "",
+		"Private key file of the https listeners, reloaded once rotated")
+	flags.StringVar(&s.TLS.ClientCAFile, "tls-client-ca-file", "",
+		"CA bundle to verify the client certificates of the https listeners, "+
+			"the client certificate is required if specified, reloaded once rotated")
+	flags.StringVar(&s.PolicyFile, "authorization-policy-file", "",
+		"Authorization policy file mapping the client subjects to the allowed providers, clusters, tenants and roles, "+
+			"allow all if not specified")
+	flags.StringVar(&s.Cache, "cache", "memory://",
+		"Cache DSN, e.g. memory://?buckets=64&capacity=1, tiered:// for memory over file with warm start, redis://host:6379/0 or none://")
+	flags.StringVar(&s.CacheKey, "cache-key-file", "",
+		"Key secret file to digest the cache keys, created if not found, "+
+			"must be shared by the replicas sharing a cache, default is the "+cache.EnvKeySecretFile+
+			" environment variable or the .secret file in the data dir")
+	flags.BoolVar(&s.Admin, "enable-admin", false,
+		"Enable the admin APIs to list, inspect and remove the cached tokens, "+
+			"requires --authorization-policy-file")
+	flags.BoolVar(&s.ExpandEnv, "expand-env", false,
+		"Expand the requested credentials of the form $VAR from the environment of the service, "+
+			"which exposes the hosted credentials to all the authorized clients, "+
+			"disabled at default, so the clients sending $VAR credentials are refused unless enabled")
+	flags.Float64Var(&s.Refresh.Fraction, "refresh-ahead-fraction", 0,
+		"Refresh the requested token in the background once this fraction of its lifetime elapsed, e.g. 0.75, "+
+			"disabled if 0")
+	flags.DurationVar(&s.Refresh.IdleTimeout, "refresh-idle-timeout", 10*time.Minute,
+		"Stop refreshing the token which is not requested in this period")
 }
 
 func (s *Server) Serve(ctx context.Context) error {
-	ls, err := newUnixListener(s.Socket)
+	// The admin APIs expose the whole cache,
+	// never serve them without authorization.
+	if s.Admin && s.PolicyFile == "" {
+		return errors.New("invalid options: --enable-admin requires --authorization-policy-file")
+	}
+
+	err := cache.LoadKeySecret(s.CacheKey)
+	if err != nil {
+		return fmt.Errorf("error loading cache key: %w", err)
+	}
+
+	az, err := NewAuthorizer(s.PolicyFile)
+	if err != nil {
+		return fmt.Errorf("error creating authorizer: %w", err)
+	}
+
+	lss, err := s.listen(ctx)
 	if err != nil {
-		return fmt.Errorf("error creating unix listener %s: %w", s.Socket, err)
+		return err
 	}
 
 	defer func() {
-		_ = ls.Close()
+		for i := range lss {
+			_ = lss[i].Close()
+		}
 	}()
 
-	c, err := cache.NewMemory(ctx)
+	c, err := cache.Open(ctx, s.Cache)
 	if err != nil {
 		return fmt.Errorf("error creating cache: %w", err)
 	}
@@ -57,13 +132,28 @@ func (s *Server) Serve(ctx context.Context) error {
 		_ = c.Close()
 	}()
 
+	r, err := NewRefresher(s.Refresh)
+	if err != nil {
+		return fmt.Errorf("error creating refresher: %w", err)
+	}
+
+	go r.Start(ctx)
+
 	m := http.NewServeMux()
 	o := ServeOptions{
-		Cache: cache.WithSingleFlight(c),
+		Cache:      c,
+		Refresher:  r,
+		Authorizer: az,
+		ExpandEnv:  s.ExpandEnv,
 	}
 
-	for i := range s.ServeFuncs {
-		err = s.ServeFuncs[i](ctx, m, o)
+	sfs := s.ServeFuncs
+	if s.Admin {
+		sfs = append(sfs[:len(sfs):len(sfs)], ServeAdmin)
+	}
+
+	for i := range sfs {
+		err = sfs[i](ctx, m, o)
 		if err != nil {
 			return fmt.Errorf("error serving: %w", err)
 		}
@@ -71,25 +161,135 @@ func (s *Server) Serve(ctx context.Context) error {
 
 	logger := klog.LoggerWithName(klog.Background(), "http")
 
-	srv := http.Server{
-		Handler:      m,
-		ReadTimeout:  1 * time.Second,
-		WriteTimeout: 5 * time.Second,
-		ErrorLog:     log.New(httpLogger(logger), "", 0),
+	srvs := make([]*http.Server, len(lss))
+
+	for i := range lss {
+		srvs[i] = newHTTPServer(lss[i], m, logger)
+	}
+
+	closeAll := func() {
+		for i := range srvs {
+			_ = srvs[i].Close()
+		}
 	}
 
 	go func() {
 		<-ctx.Done()
 
-		_ = srv.Close()
+		closeAll()
 	}()
 
-	err = srv.Serve(ls)
-	if err != nil && !errors.Is(err, http.ErrServerClosed) {
-		return err
+	g := errgroup.Group{}
+
+	for i := range lss {
+		srv, ls := srvs[i], lss[i]
+
+		g.Go(func() error {
+			err := srv.Serve(ls)
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				// Stop other listeners.
+				closeAll()
+				return fmt.Errorf("error serving %s: %w", ls.Addr(), err)
+			}
+
+			return nil
+		})
 	}
 
-	return nil
+	return g.Wait()
+}
+
+// newHTTPServer returns the http.Server serving the given listener.
+func newHTTPServer(ls net.Listener, h http.Handler, logger klog.Logger) *http.Server {
+	srv := &http.Server{
+		Handler:     h,
+		ReadTimeout: 1 * time.Second,
+		// Outlast the upstream requests of the providers,
+		// which time out in 30 seconds.
+		WriteTimeout: 40 * time.Second,
+		ErrorLog:     log.New(httpLogger(logger), "", 0),
+		ConnContext:  withPeerCredentials,
+	}
+
+	// The network listeners read over the real networks,
+	// including the (m)TLS handshake.
+	if ls.Addr().Network() != "unix" {
+		srv.ReadHeaderTimeout = 10 * time.Second
+		srv.ReadTimeout = 30 * time.Second
+	}
+
+	return srv
+}
+
+// listen creates the listeners of the --listen addresses,
+// or the --socket path if not specified.
+func (s *Server) listen(ctx context.Context) ([]net.Listener, error) {
+	addrs := s.Listen
+	if len(addrs) == 0 {
+		addrs = []string{"unix://" + s.Socket}
+	}
+
+	las := make([]listenAddress, 0, len(addrs))
+	lss := make([]net.Listener, 0, len(addrs))
+
+	var unix, https bool
+
+	for i := range addrs {
+		a, err := parseListenAddress(addrs[i])
+		if err != nil {
+			return nil, err
+		}
+
+		// Never expose the cloud credentials to the network without authorization.
+		if a.scheme != "unix" && s.PolicyFile == "" {
+			return nil, fmt.Errorf("invalid listen address %q: %s listener requires --authorization-policy-file",
+				addrs[i], a.scheme)
+		}
+
+		las = append(las, a)
+		unix = unix || a.scheme == "unix"
+		https = https || a.scheme == "https"
+	}
+
+	s.UnixSocket.Default()
+
+	sock, err := s.UnixSocket.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	if unix && sock.mode&0o007 != 0 && s.PolicyFile == "" {
+		klog.Warningf("unix sockets with mode %s are accessible to all local users, "+
+			"please restrict them by --authorization-policy-file\n", s.UnixSocket.Mode)
+	}
+
+	var cert *certificateReloader
+
+	if https {
+		cert, err = newCertificateReloader(s.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("error loading tls certificate: %w", err)
+		}
+
+		go cert.Start(ctx)
+	}
+
+	for i := range las {
+		ls, err := newListener(las[i], cert, sock)
+		if err != nil {
+			for j := range lss {
+				_ = lss[j].Close()
+			}
+
+			return nil, fmt.Errorf("error creating listener %s: %w", las[i], err)
+		}
+
+		klog.Infof("listening on %s\n", las[i])
+
+		lss = append(lss, ls)
+	}
+
+	return lss, nil
 }
 
 func (s *Server) Register(f ServeFunc) {
@@ -100,10 +300,10 @@ func (s *Server) Register(f ServeFunc) {
 	s.ServeFuncs = append(s.ServeFuncs, f)
 }
 
-func newUnixListener(sock string) (net.Listener, error) {
-	err := os.MkdirAll(filepath.Dir(sock), 0o700)
-	if err != nil && !os.IsExist(err) {
-		return nil, fmt.Errorf("error creating unix socket dir: %w", err)
+func newUnixListener(sock string, o socketOptions) (net.Listener, error) {
+	err := mkSocketDir(filepath.Dir(sock), o)
+	if err != nil {
+		return nil, err
 	}
 
 	err = syscall.Unlink(sock)
@@ -116,15 +316,62 @@ func newUnixListener(sock string) (net.Listener, error) {
 		return nil, fmt.Errorf("error creating unix socket listener: %w", err)
 	}
 
-	err = os.Chmod(sock, 0o777)
+	err = os.Chmod(sock, o.mode)
 	if err != nil {
 		_ = ls.Close()
 		return nil, fmt.Errorf("error chmoding unix socket: %w", err)
 	}
 
+	if o.uid != -1 || o.gid != -1 {
+		err = os.Chown(sock, o.uid, o.gid)
+		if err != nil {
+			_ = ls.Close()
+			return nil, fmt.Errorf("error chowning unix socket: %w", err)
+		}
+	}
+
 	return ls, nil
 }
 
+// mkSocketDir creates the dir of the unix socket if not found,
+// which is traversable by whom the socket is accessible to, and owned by the owner and group of the socket,
+// the existing dir is left alone.
+func mkSocketDir(dir string, o socketOptions) error {
+	_, err := os.Stat(dir)
+	if err == nil || !os.IsNotExist(err) {
+		return nil
+	}
+
+	perm := os.FileMode(0o700)
+	if o.mode&0o060 != 0 {
+		perm |= 0o050
+	}
+
+	if o.mode&0o006 != 0 {
+		perm |= 0o005
+	}
+
+	err = os.MkdirAll(dir, perm)
+	if err != nil && !os.IsExist(err) {
+		return fmt.Errorf("error creating unix socket dir: %w", err)
+	}
+
+	// Ignore the umask.
+	err = os.Chmod(dir, perm)
+	if err != nil {
+		return fmt.Errorf("error chmoding unix socket dir: %w", err)
+	}
+
+	if o.uid != -1 || o.gid != -1 {
+		err = os.Chown(dir, o.uid, o.gid)
+		if err != nil {
+			return fmt.Errorf("error chowning unix socket dir: %w", err)
+		}
+	}
+
+	return nil
+}
+
 type httpLogger klog.Logger
 
 func (l httpLogger) Write(p []byte) (int, error) {
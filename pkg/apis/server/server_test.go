@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+func TestServer_Serve_admin(t *testing.T) {
+	s := Server{
+		Socket: t.TempDir() + "/kubecia.sock",
+		Cache:  "none://",
+		Admin:  true,
+	}
+
+	err := s.Serve(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "--authorization-policy-file") {
+		t.Errorf("expected error of the missing policy, got %v", err)
+	}
+}
+
+func TestServer_listen_policy(t *testing.T) {
+	cases := []struct {
+		name   string
+		listen string
+		policy string
+		valid  bool
+	}{
+		{name: "unix without policy", listen: "unix://" + t.TempDir() + "/kubecia.sock", valid: true},
+		{name: "tcp without policy", listen: "tcp://127.0.0.1:0"},
+		{name: "https without policy", listen: "https://127.0.0.1:0"},
+		{name: "tcp with policy", listen: "tcp://127.0.0.1:0", policy: "policy.yaml", valid: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := Server{
+				Listen:     []string{tc.listen},
+				PolicyFile: tc.policy,
+			}
+
+			lss, err := s.listen(context.Background())
+			if tc.valid != (err == nil) {
+				t.Fatalf("expected valid %v, got error %v", tc.valid, err)
+			}
+
+			for i := range lss {
+				_ = lss[i].Close()
+			}
+		})
+	}
+}
+
+func TestNewHTTPServer_timeout(t *testing.T) {
+	s := Server{
+		Listen:     []string{"unix://" + t.TempDir() + "/kubecia.sock", "tcp://127.0.0.1:0"},
+		PolicyFile: "policy.yaml",
+	}
+
+	lss, err := s.listen(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Cleanup(func() {
+		for i := range lss {
+			_ = lss[i].Close()
+		}
+	})
+
+	// Keep the local reading short.
+	if srv := newHTTPServer(lss[0], nil, klog.Background()); srv.ReadTimeout != time.Second || srv.ReadHeaderTimeout != 0 {
+		t.Errorf("expected 1s read timeout of unix listener, got %s, %s", srv.ReadTimeout, srv.ReadHeaderTimeout)
+	}
+
+	// Leave the time for the (m)TLS handshake and reading over the networks.
+	if srv := newHTTPServer(lss[1], nil, klog.Background()); srv.ReadTimeout <= time.Second || srv.ReadHeaderTimeout == 0 {
+		t.Errorf("expected longer read timeouts of tcp listener, got %s, %s", srv.ReadTimeout, srv.ReadHeaderTimeout)
+	}
+}
+
+func TestServer_listen_socketMode(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		sock = filepath.Join(dir, "run", "kubecia.sock")
+	)
+
+	if err := os.Chmod(dir, 0o711); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s := Server{Socket: sock}
+
+	lss, err := s.listen(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Cleanup(func() {
+		for i := range lss {
+			_ = lss[i].Close()
+		}
+	})
+
+	fi, err := os.Stat(sock)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if m := fi.Mode().Perm(); m != 0o660 {
+		t.Errorf("expected default mode 0660, got %o", m)
+	}
+
+	// Let the group traverse the created dir.
+	fi, err = os.Stat(filepath.Dir(sock))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if m := fi.Mode().Perm(); m != 0o750 {
+		t.Errorf("expected created dir mode 0750, got %o", m)
+	}
+
+	// Leave the existing dir alone.
+	fi, err = os.Stat(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if m := fi.Mode().Perm(); m != 0o711 {
+		t.Errorf("expected existing dir mode 0711, got %o", m)
+	}
+}
+
+func TestServeOptions_RefuseEnv(t *testing.T) {
+	cases := []struct {
+		name      string
+		expandEnv bool
+		creds     []string
+		refused   bool
+	}{
+		{name: "plain credentials", creds: []string{"id", "secret"}},
+		{name: "blank credentials", creds: []string{"", ""}},
+		{name: "env credential", creds: []string{"id", "$SECRET"}, refused: true},
+		{name: "env credential expandable", expandEnv: true, creds: []string{"$ID", "$SECRET"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				o = ServeOptions{ExpandEnv: tc.expandEnv}
+				w = httptest.NewRecorder()
+			)
+
+			if refused := o.RefuseEnv(w, tc.creds...); refused != tc.refused {
+				t.Fatalf("expected refused %v, got %v", tc.refused, refused)
+			}
+
+			if tc.refused && w.Code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d", w.Code)
+			}
+
+			// Tell the clients how to restore expanding.
+			if tc.refused && !strings.Contains(w.Body.String(), "--expand-env") {
+				t.Errorf("expected the refusal message pointing to --expand-env, got %q", w.Body.String())
+			}
+		})
+	}
+}
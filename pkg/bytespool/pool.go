@@ -17,7 +17,7 @@ var (
 
 	bufferPool = sync.Pool{
 		New: func() any {
-			return bytes.NewBuffer(GetBytes(0))
+			return bytes.NewBuffer(GetBytes(0)[:0])
 		},
 	}
 )
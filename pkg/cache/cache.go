@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"time"
 )
 
 var (
@@ -18,9 +19,14 @@ type Cache interface {
 	Name() string
 
 	// Set saves entry with the given key,
+	// the entry lives in the configured maximum age,
 	// it returns an ErrEntryTooBig when entry is too big.
 	Set(ctx context.Context, key string, entry []byte) error
 
+	// SetWithTTL likes Set, but the entry lives in the given ttl,
+	// a non-positive ttl means the entry is expired already, and nothing is saved.
+	SetWithTTL(ctx context.Context, key string, entry []byte, ttl time.Duration) error
+
 	// Delete removes the given key.
 	Delete(ctx context.Context, key string) ([]byte, error)
 
@@ -28,3 +34,41 @@ type Cache interface {
 	// it returns an ErrEntryNotFound when no entry exists for the given key.
 	Get(ctx context.Context, key string) ([]byte, error)
 }
+
+// Locker holds the action of locking a key,
+// which is implemented by the Cache shared across processes.
+type Locker interface {
+	// Lock blocks until holding the lock of the given key or the context is done,
+	// the returned function releases the lock.
+	Lock(ctx context.Context, key string) (unlock func(), err error)
+}
+
+// TTLGetter holds the action of reading entry along with its remaining lifetime,
+// which is implemented by the Cache tracking the expiration of each entry.
+type TTLGetter interface {
+	// GetWithTTL likes Get, but also returns the remaining lifetime of the entry.
+	GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error)
+}
+
+// EntryInfo describes an entry of the Cache.
+type EntryInfo struct {
+	// Key is the key of the entry.
+	Key string
+	// Size is the size of the entry.
+	Size int
+	// TTL is the remaining lifetime of the entry.
+	TTL time.Duration
+}
+
+// Lister holds the action of listing entries,
+// which is implemented by the Cache able to enumerate its keys.
+type Lister interface {
+	// List returns the information of all unexpired entries.
+	List(ctx context.Context) ([]EntryInfo, error)
+}
+
+// Pruner holds the action of evicting expired entries.
+type Pruner interface {
+	// Prune removes all expired entries, and returns the removed count.
+	Prune(ctx context.Context) (int, error)
+}
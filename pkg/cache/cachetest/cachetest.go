@@ -0,0 +1,621 @@
+// Package cachetest implements the conformance checking of the cache.Cache implementations,
+// which is usable by both the built-in and third-party backends.
+//
+// For example, check a backend in its own test:
+//
+//	func TestConformance(t *testing.T) {
+//		cachetest.Run(t, func(ctx context.Context, ns string) (cache.Cache, error) {
+//			return NewMyCache(ctx, MyConfig{Namespace: ns})
+//		})
+//	}
+package cachetest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seal-io/kubecia/pkg/cache"
+)
+
+// Opener opens a Cache of the given namespace,
+// the Caches of different namespaces must not observe the entries of each other,
+// while the Caches of the same namespace must share the entries if the Cache is persistent.
+type Opener = func(ctx context.Context, namespace string) (cache.Cache, error)
+
+// OpenDSN returns an Opener of the given DSN,
+// which specifies the namespace by the query parameter,
+// the namespace is specified to both tiers if the DSN is a tiered one.
+func OpenDSN(dsn string) Opener {
+	return func(ctx context.Context, namespace string) (cache.Cache, error) {
+		if namespace == "" {
+			return cache.Open(ctx, dsn)
+		}
+
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing dsn: %w", err)
+		}
+
+		q := u.Query()
+
+		if u.Scheme == "tiered" {
+			for k, d := range map[string]string{"front": "memory://", "back": "file://"} {
+				if q.Get(k) != "" {
+					d = q.Get(k)
+				}
+
+				tu, err := url.Parse(d)
+				if err != nil {
+					return nil, fmt.Errorf("error parsing %s dsn: %w", k, err)
+				}
+
+				tq := tu.Query()
+				tq.Set("namespace", namespace)
+				tu.RawQuery = tq.Encode()
+				q.Set(k, tu.String())
+			}
+		} else {
+			q.Set("namespace", namespace)
+		}
+
+		u.RawQuery = q.Encode()
+
+		return cache.Open(ctx, u.String())
+	}
+}
+
+// Discarder is implemented by the Cache which saves nothing by design, e.g. none://,
+// the checks reading the saved entries are skipped if Discards returns true.
+type Discarder interface {
+	Discards() bool
+}
+
+// errSkipped is returned by the check which is not applicable to the Cache.
+var errSkipped = errors.New("skipped")
+
+// check is a conformance check.
+type check struct {
+	name string
+	fn   func(ctx context.Context, t *tester) error
+	// stores indicates the check reads the saved entries.
+	stores bool
+	// exclusive indicates the check affects the entries of others, e.g. pruning the whole Cache,
+	// which is only run against a Cache opened for testing by Run.
+	exclusive bool
+}
+
+var checks = []check{
+	{name: "name", fn: checkName},
+	{name: "not found", fn: checkNotFound},
+	{name: "set and get", fn: checkSetGet, stores: true},
+	{name: "too big", fn: checkTooBig, stores: true},
+	{name: "ttl expiry", fn: checkTTLExpiry, stores: true},
+	{name: "delete", fn: checkDelete, stores: true},
+	{name: "namespace", fn: checkNamespace, stores: true},
+	{name: "concurrency", fn: checkConcurrency, stores: true},
+	{name: "lock", fn: checkLock},
+	{name: "list", fn: checkList, stores: true},
+	{name: "prune", fn: checkPrune, stores: true, exclusive: true},
+	{name: "close", fn: checkClose},
+}
+
+// TestCache checks the Cache opened by the given Opener conforms to the semantics of cache.Cache,
+// and the optional interfaces it implements, like cache.Locker and cache.Lister.
+//
+// The checking saves entries with random keys, and deletes them at the end,
+// the exclusive checks like pruning are not run,
+// so it is safe to run against a shared backend,
+// it returns the joined errors of the failed checks, the skipped checks are not errors.
+func TestCache(ctx context.Context, open Opener) error {
+	var errs []error
+
+	for _, ck := range checks {
+		if ck.exclusive {
+			continue
+		}
+
+		err := runCheck(ctx, open, ck)
+		if err != nil && !errors.Is(err, errSkipped) {
+			errs = append(errs, fmt.Errorf("%s: %w", ck.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Run likes TestCache, but runs each check as a subtest of the given testing.T,
+// and marks the checks not applicable to the Cache as skipped,
+// the exclusive checks are run as well, so the given Opener must open a Cache dedicated for testing.
+func Run(t *testing.T, open Opener) {
+	t.Helper()
+
+	for _, ck := range checks {
+		ck := ck
+
+		t.Run(ck.name, func(t *testing.T) {
+			err := runCheck(context.Background(), open, ck)
+			switch {
+			case errors.Is(err, errSkipped):
+				t.Skip(err)
+			case err != nil:
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// tester holds the Caches opened during a check.
+type tester struct {
+	open   Opener
+	prefix string
+	c      cache.Cache
+	opened []cache.Cache
+	keys   map[cache.Cache][]string
+	mu     sync.Mutex
+}
+
+func runCheck(ctx context.Context, open Opener, ck check) error {
+	bs := make([]byte, 6)
+	_, _ = rand.Read(bs)
+
+	t := &tester{
+		open:   open,
+		prefix: "cachetest-" + hex.EncodeToString(bs) + "-",
+		keys:   map[cache.Cache][]string{},
+	}
+
+	c, err := t.openCache(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	t.c = c
+
+	defer t.cleanup(ctx)
+
+	if d, ok := c.(Discarder); ok && d.Discards() && ck.stores {
+		return fmt.Errorf("%w: %s saves nothing", errSkipped, c.Name())
+	}
+
+	return ck.fn(ctx, t)
+}
+
+// openCache opens a Cache of the given namespace, which is closed after the check.
+func (t *tester) openCache(ctx context.Context, namespace string) (cache.Cache, error) {
+	c, err := t.open(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error opening cache: %w", err)
+	}
+
+	if c == nil {
+		return nil, errors.New("error opening cache: nil")
+	}
+
+	t.opened = append(t.opened, c)
+
+	return c, nil
+}
+
+// key returns a random key with the given name, which is deleted from the given Cache after the check.
+func (t *tester) key(c cache.Cache, name string) string {
+	k := t.prefix + name
+
+	t.mu.Lock()
+	t.keys[c] = append(t.keys[c], k)
+	t.mu.Unlock()
+
+	return k
+}
+
+func (t *tester) cleanup(ctx context.Context) {
+	for _, c := range t.opened {
+		for _, k := range t.keys[c] {
+			_, _ = c.Delete(ctx, k)
+		}
+
+		_ = c.Close()
+	}
+}
+
+func checkName(_ context.Context, t *tester) error {
+	if t.c.Name() == "" {
+		return errors.New("blank name")
+	}
+
+	return nil
+}
+
+func checkNotFound(ctx context.Context, t *tester) error {
+	k := t.key(t.c, "missing")
+
+	if _, err := t.c.Get(ctx, k); !errors.Is(err, cache.ErrEntryNotFound) {
+		return fmt.Errorf("get missing entry: want ErrEntryNotFound, got %v", err)
+	}
+
+	if _, err := t.c.Delete(ctx, k); !errors.Is(err, cache.ErrEntryNotFound) {
+		return fmt.Errorf("delete missing entry: want ErrEntryNotFound, got %v", err)
+	}
+
+	if g, ok := t.c.(cache.TTLGetter); ok {
+		if _, _, err := g.GetWithTTL(ctx, k); !errors.Is(err, cache.ErrEntryNotFound) {
+			return fmt.Errorf("get missing entry with ttl: want ErrEntryNotFound, got %v", err)
+		}
+	}
+
+	return nil
+}
+
+func checkSetGet(ctx context.Context, t *tester) error {
+	k := t.key(t.c, "set")
+
+	if err := t.c.Set(ctx, k, []byte("v1")); err != nil {
+		return fmt.Errorf("set: %w", err)
+	}
+
+	if err := expectEntry(ctx, t.c, k, []byte("v1")); err != nil {
+		return err
+	}
+
+	// Overwrite.
+	if err := t.c.SetWithTTL(ctx, k, []byte("v2"), time.Minute); err != nil {
+		return fmt.Errorf("overwrite: %w", err)
+	}
+
+	if err := expectEntry(ctx, t.c, k, []byte("v2")); err != nil {
+		return err
+	}
+
+	if g, ok := t.c.(cache.TTLGetter); ok {
+		_, ttl, err := g.GetWithTTL(ctx, k)
+		if err != nil {
+			return fmt.Errorf("get with ttl: %w", err)
+		}
+
+		if ttl <= 0 || ttl > time.Minute {
+			return fmt.Errorf("get with ttl: want (0, 1m], got %s", ttl)
+		}
+	}
+
+	return nil
+}
+
+// checkTooBig saves a large entry,
+// which must be either readable or rejected by cache.ErrEntryTooBig.
+func checkTooBig(ctx context.Context, t *tester) error {
+	k := t.key(t.c, "big")
+	v := bytes.Repeat([]byte("x"), 4<<20)
+
+	err := t.c.Set(ctx, k, v)
+	if err != nil {
+		if errors.Is(err, cache.ErrEntryTooBig) {
+			return nil
+		}
+
+		return fmt.Errorf("set: want nil or ErrEntryTooBig, got %w", err)
+	}
+
+	return expectEntry(ctx, t.c, k, v)
+}
+
+func checkTTLExpiry(ctx context.Context, t *tester) error {
+	k := t.key(t.c, "ttl")
+
+	if err := t.c.SetWithTTL(ctx, k, []byte("v"), time.Second); err != nil {
+		return fmt.Errorf("set: %w", err)
+	}
+
+	if err := expectEntry(ctx, t.c, k, []byte("v")); err != nil {
+		return err
+	}
+
+	if err := sleep(ctx, 1500*time.Millisecond); err != nil {
+		return err
+	}
+
+	if _, err := t.c.Get(ctx, k); !errors.Is(err, cache.ErrEntryNotFound) {
+		return fmt.Errorf("get expired entry: want ErrEntryNotFound, got %v", err)
+	}
+
+	return nil
+}
+
+func checkDelete(ctx context.Context, t *tester) error {
+	k := t.key(t.c, "delete")
+
+	if err := t.c.Set(ctx, k, []byte("v")); err != nil {
+		return fmt.Errorf("set: %w", err)
+	}
+
+	v, err := t.c.Delete(ctx, k)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	if !bytes.Equal(v, []byte("v")) {
+		return fmt.Errorf("delete: want the deleted entry %q, got %q", "v", v)
+	}
+
+	if _, err = t.c.Get(ctx, k); !errors.Is(err, cache.ErrEntryNotFound) {
+		return fmt.Errorf("get deleted entry: want ErrEntryNotFound, got %v", err)
+	}
+
+	if _, err = t.c.Delete(ctx, k); !errors.Is(err, cache.ErrEntryNotFound) {
+		return fmt.Errorf("delete deleted entry: want ErrEntryNotFound, got %v", err)
+	}
+
+	// Save again after deleting.
+	if err = t.c.Set(ctx, k, []byte("v2")); err != nil {
+		return fmt.Errorf("set deleted entry: %w", err)
+	}
+
+	return expectEntry(ctx, t.c, k, []byte("v2"))
+}
+
+func checkNamespace(ctx context.Context, t *tester) error {
+	a, err := t.openCache(ctx, "cachetest-a")
+	if err != nil {
+		return err
+	}
+
+	b, err := t.openCache(ctx, "cachetest-b")
+	if err != nil {
+		return err
+	}
+
+	ka, kb := t.key(a, "ns"), t.key(b, "ns")
+
+	if err = a.Set(ctx, ka, []byte("a")); err != nil {
+		return fmt.Errorf("set in namespace a: %w", err)
+	}
+
+	if _, err = b.Get(ctx, kb); !errors.Is(err, cache.ErrEntryNotFound) {
+		return fmt.Errorf("get in namespace b: want ErrEntryNotFound, got %v", err)
+	}
+
+	if err = b.Set(ctx, kb, []byte("b")); err != nil {
+		return fmt.Errorf("set in namespace b: %w", err)
+	}
+
+	if err = expectEntry(ctx, a, ka, []byte("a")); err != nil {
+		return fmt.Errorf("namespace a: %w", err)
+	}
+
+	return expectEntry(ctx, b, kb, []byte("b"))
+}
+
+func checkConcurrency(ctx context.Context, t *tester) error {
+	const (
+		workers = 8
+		rounds  = 20
+	)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	shared := t.key(t.c, "shared")
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			own := t.key(t.c, fmt.Sprintf("own-%d", i))
+
+			for j := 0; j < rounds; j++ {
+				v := []byte(fmt.Sprintf("%d-%d", i, j))
+
+				err := errors.Join(
+					t.c.Set(ctx, own, v),
+					expectEntry(ctx, t.c, own, v),
+					t.c.Set(ctx, shared, v))
+				if err == nil {
+					// Other workers may overwrite or be overwriting.
+					_, err = t.c.Get(ctx, shared)
+				}
+
+				if err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("worker %d round %d: %w", i, j, err))
+					mu.Unlock()
+
+					return
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func checkLock(ctx context.Context, t *tester) error {
+	l, ok := t.c.(cache.Locker)
+	if !ok {
+		return nil
+	}
+
+	k := t.key(t.c, "lock")
+
+	unlock, err := l.Lock(ctx, k)
+	if err != nil {
+		return fmt.Errorf("lock: %w", err)
+	}
+
+	// Must not acquire the held lock.
+	tctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+
+	if u, err := l.Lock(tctx, k); err == nil {
+		u()
+		unlock()
+
+		return errors.New("lock held lock: want error, got acquired")
+	}
+
+	unlock()
+
+	tctx, cancel = context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	unlock, err = l.Lock(tctx, k)
+	if err != nil {
+		return fmt.Errorf("lock released lock: %w", err)
+	}
+
+	unlock()
+
+	return nil
+}
+
+func checkList(ctx context.Context, t *tester) error {
+	l, ok := t.c.(cache.Lister)
+	if !ok {
+		return nil
+	}
+
+	k, dk := t.key(t.c, "list"), t.key(t.c, "list-deleted")
+
+	err := errors.Join(
+		t.c.Set(ctx, k, []byte("v")),
+		t.c.Set(ctx, dk, []byte("v")))
+	if err != nil {
+		return fmt.Errorf("set: %w", err)
+	}
+
+	if _, err = t.c.Delete(ctx, dk); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	eis, err := l.List(ctx)
+	if err != nil {
+		return fmt.Errorf("list: %w", err)
+	}
+
+	var found bool
+
+	for i := range eis {
+		switch eis[i].Key {
+		case k:
+			found = true
+
+			if eis[i].Size != 1 {
+				return fmt.Errorf("list: want size 1, got %d", eis[i].Size)
+			}
+
+			if eis[i].TTL <= 0 {
+				return fmt.Errorf("list: want positive ttl, got %s", eis[i].TTL)
+			}
+		case dk:
+			return errors.New("list: want deleted entry absent, got present")
+		}
+	}
+
+	if !found {
+		return errors.New("list: want saved entry present, got absent")
+	}
+
+	return nil
+}
+
+func checkPrune(ctx context.Context, t *tester) error {
+	p, ok := t.c.(cache.Pruner)
+	if !ok {
+		return nil
+	}
+
+	k := t.key(t.c, "prune")
+
+	if err := t.c.SetWithTTL(ctx, k, []byte("v"), time.Second); err != nil {
+		return fmt.Errorf("set: %w", err)
+	}
+
+	if err := sleep(ctx, 1500*time.Millisecond); err != nil {
+		return err
+	}
+
+	if _, err := p.Prune(ctx); err != nil {
+		return fmt.Errorf("prune: %w", err)
+	}
+
+	if l, ok := t.c.(cache.Lister); ok {
+		eis, err := l.List(ctx)
+		if err != nil {
+			return fmt.Errorf("list: %w", err)
+		}
+
+		for i := range eis {
+			if eis[i].Key == k {
+				return errors.New("list: want pruned entry absent, got present")
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkClose(ctx context.Context, t *tester) error {
+	c, err := t.openCache(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	// Remove from the opened list, it is closed here.
+	t.opened = t.opened[:len(t.opened)-1]
+
+	if err = c.Close(); err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+
+	return nil
+}
+
+// expectEntry checks the entry of the given key is the given value.
+func expectEntry(ctx context.Context, c cache.Cache, key string, want []byte) error {
+	// Trim the random prefix.
+	name := key
+	if ss := strings.SplitN(key, "-", 3); len(ss) == 3 {
+		name = ss[2]
+	}
+
+	got, err := c.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("get %s: %w", name, err)
+	}
+
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("get %s: want %q, got %q", name, abbrev(want), abbrev(got))
+	}
+
+	return nil
+}
+
+func abbrev(bs []byte) []byte {
+	if len(bs) > 16 {
+		return append(bs[:16:16], "..."...)
+	}
+
+	return bs
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
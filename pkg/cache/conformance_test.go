@@ -0,0 +1,106 @@
+package cache_test
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/seal-io/kubecia/pkg/cache"
+	"github.com/seal-io/kubecia/pkg/cache/cachetest"
+)
+
+func TestConformance(t *testing.T) {
+	cases := []struct {
+		name string
+		open func(t *testing.T) cachetest.Opener
+	}{
+		{
+			name: "memory",
+			open: func(t *testing.T) cachetest.Opener {
+				return cachetest.OpenDSN("memory://")
+			},
+		},
+		{
+			name: "file",
+			open: func(t *testing.T) cachetest.Opener {
+				return cachetest.OpenDSN("file://" + t.TempDir())
+			},
+		},
+		{
+			name: "tiered",
+			open: func(t *testing.T) cachetest.Opener {
+				dir := t.TempDir()
+
+				// Keep the keys under the temporary dir.
+				q := url.Values{
+					"back":           []string{"file://" + dir},
+					"snapshot":       []string{filepath.Join(dir, "tiered.snapshot")},
+					"encryption_key": []string{cache.EncryptionKeyFilePrefix + filepath.Join(dir, ".cache.key")},
+				}
+
+				return cachetest.OpenDSN("tiered://?" + q.Encode())
+			},
+		},
+		{
+			name: "none",
+			open: func(t *testing.T) cachetest.Opener {
+				return cachetest.OpenDSN("none://")
+			},
+		},
+		{
+			name: "redis",
+			open: func(t *testing.T) cachetest.Opener {
+				mr := miniredis.RunT(t)
+
+				// Miniredis never expires the keys by itself,
+				// move its clock along with the wall clock.
+				done := make(chan struct{})
+				t.Cleanup(func() { close(done) })
+
+				go func() {
+					tk := time.NewTicker(50 * time.Millisecond)
+					defer tk.Stop()
+
+					for {
+						select {
+						case <-done:
+							return
+						case <-tk.C:
+							mr.FastForward(50 * time.Millisecond)
+						}
+					}
+				}()
+
+				return cachetest.OpenDSN("redis://" + mr.Addr() + "/0")
+			},
+		},
+		{
+			name: "kubernetes",
+			open: func(t *testing.T) cachetest.Opener {
+				cli := fake.NewSimpleClientset()
+
+				return func(ctx context.Context, ns string) (cache.Cache, error) {
+					return cache.NewKubernetesWithClient(ctx, cli, cache.KubernetesConfig{
+						Namespace:       ns,
+						ObjectNamespace: "kubecia",
+					})
+				}
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cachetest.Run(t, tc.open(t))
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/seal-io/kubecia/pkg/consts"
+)
+
+// Encryption key sources of the cache entries.
+const (
+	// EncryptionKeyNone stores entries in plaintext.
+	EncryptionKeyNone = "none"
+	// EncryptionKeyMachineID derives the key from the machine ID.
+	EncryptionKeyMachineID = "machine-id"
+	// EncryptionKeyKeyring reads the key from the user keyring of the kernel, only supported on linux,
+	// the key is created with random bytes if not found.
+	EncryptionKeyKeyring = "keyring"
+	// EncryptionKeyFilePrefix reads the key from the given file,
+	// the file is created with a random key if not found.
+	EncryptionKeyFilePrefix = "file:"
+	// EncryptionKeyEnvPrefix reads the key from the given environment variable.
+	EncryptionKeyEnvPrefix = "env:"
+)
+
+// EnvEncryptionKey is the environment variable of the default encryption key source,
+// e.g. "file:/etc/kubecia/cache.key" to keep the key outside the cache dir,
+// the key file under the cache dir is used if blank.
+const EnvEncryptionKey = "KUBECIA_CACHE_ENCRYPTION_KEY"
+
+// defaultEncryptionKey returns the encryption key source of EnvEncryptionKey,
+// or the key file under the given dir.
+func defaultEncryptionKey(dir string) string {
+	if s := strings.TrimSpace(os.Getenv(EnvEncryptionKey)); s != "" {
+		return s
+	}
+
+	return EncryptionKeyFilePrefix + filepath.Join(dir, ".cache.key")
+}
+
+// encryptedEntryMagic prefixes the encrypted entry, and versions the format.
+var encryptedEntryMagic = []byte("KCE1")
+
+// entryCipher encrypts the entries with AES-GCM,
+// and hashes the entry names to not leak the identifiers within the keys.
+type entryCipher struct {
+	aead    cipher.AEAD
+	nameKey []byte
+}
+
+func newEntryCipher(source string) (*entryCipher, error) {
+	m, err := loadEncryptionKeyMaterial(source)
+	if err != nil {
+		return nil, err
+	}
+
+	// Derive independent keys for encryption and naming.
+	b, err := aes.NewCipher(deriveKey(m, "kubecia/cache/file/encryption"))
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(b)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+
+	fc := &entryCipher{
+		aead:    aead,
+		nameKey: deriveKey(m, "kubecia/cache/file/name"),
+	}
+
+	return fc, nil
+}
+
+// name returns the hashed name of the given path.
+func (c *entryCipher) name(p string) string {
+	h := hmac.New(sha256.New, c.nameKey)
+	_, _ = h.Write([]byte(p))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// seal encrypts the given entry, the name is authenticated to bind the entry to its path.
+func (c *entryCipher) seal(name string, entry []byte) ([]byte, error) {
+	ns := c.aead.NonceSize()
+
+	r := make([]byte, len(encryptedEntryMagic)+ns, len(encryptedEntryMagic)+ns+len(entry)+c.aead.Overhead())
+	copy(r, encryptedEntryMagic)
+
+	nonce := r[len(encryptedEntryMagic):]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	return c.aead.Seal(r, nonce, entry, []byte(name)), nil
+}
+
+// open decrypts the given sealed entry.
+func (c *entryCipher) open(name string, sealed []byte) ([]byte, error) {
+	ns := c.aead.NonceSize()
+
+	if len(sealed) < len(encryptedEntryMagic)+ns || !bytes.HasPrefix(sealed, encryptedEntryMagic) {
+		return nil, errors.New("invalid encrypted entry")
+	}
+
+	sealed = sealed[len(encryptedEntryMagic):]
+
+	return c.aead.Open(nil, sealed[:ns], sealed[ns:], []byte(name))
+}
+
+func deriveKey(material []byte, label string) []byte {
+	h := hmac.New(sha256.New, material)
+	_, _ = h.Write([]byte(label))
+
+	return h.Sum(nil)
+}
+
+func loadEncryptionKeyMaterial(source string) ([]byte, error) {
+	switch {
+	case source == EncryptionKeyMachineID:
+		id, err := machineID()
+		if err != nil {
+			return nil, fmt.Errorf("error reading machine id: %w", err)
+		}
+
+		// Mix with the user home, so that users on the same machine own different keys.
+		return []byte(id + "\x00" + consts.DataDir()), nil
+	case source == EncryptionKeyKeyring:
+		return keyringKey()
+	case strings.HasPrefix(source, EncryptionKeyEnvPrefix):
+		n := strings.TrimPrefix(source, EncryptionKeyEnvPrefix)
+
+		v := os.Getenv(n)
+		if v == "" {
+			return nil, fmt.Errorf("invalid encryption key: environment variable %q is blank", n)
+		}
+
+		return []byte(v), nil
+	case strings.HasPrefix(source, EncryptionKeyFilePrefix):
+		p := strings.TrimPrefix(source, EncryptionKeyFilePrefix)
+
+		m, err := os.ReadFile(p)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return nil, fmt.Errorf("error reading encryption key: %w", err)
+			}
+
+			return loadOrCreateKeySecret(p)
+		}
+
+		if len(bytes.TrimSpace(m)) == 0 {
+			return nil, fmt.Errorf("invalid encryption key %s: blank", p)
+		}
+
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("invalid encryption key source %q", source)
+}
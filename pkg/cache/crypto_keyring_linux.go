@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// keyringDescription is the description of the key in the user keyring.
+const keyringDescription = "kubecia:cache"
+
+// keyringKey returns the key kept in the user keyring of the kernel,
+// the key is created with random bytes if not found,
+// and lost at the user session ended, e.g. rebooting, which invalidates the encrypted entries.
+func keyringKey() ([]byte, error) {
+	id, err := unix.KeyctlSearch(unix.KEY_SPEC_USER_KEYRING, "user", keyringDescription, 0)
+	if err != nil {
+		if !errors.Is(err, unix.ENOKEY) {
+			return nil, fmt.Errorf("error searching user keyring: %w", err)
+		}
+
+		m := make([]byte, keySecretSize)
+		if _, err = rand.Read(m); err != nil {
+			return nil, fmt.Errorf("error generating key: %w", err)
+		}
+
+		id, err = unix.AddKey("user", keyringDescription, m, unix.KEY_SPEC_USER_KEYRING)
+		if err != nil {
+			return nil, fmt.Errorf("error adding key to user keyring: %w", err)
+		}
+	}
+
+	// Read back, another process may have updated the key just now.
+	m := make([]byte, keySecretSize)
+
+	n, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, m, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error reading key from user keyring: %w", err)
+	}
+
+	if n != keySecretSize {
+		return nil, fmt.Errorf("invalid key %q of user keyring: unexpected size", keyringDescription)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package cache
+
+import (
+	"errors"
+)
+
+func keyringKey() ([]byte, error) {
+	return nil, errors.New("keyring is only supported on linux")
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCache_encryptionKey(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		dir     = t.TempDir()
+		keyFile = filepath.Join(t.TempDir(), "cache.key")
+	)
+
+	// Keep the key outside the cache dir.
+	t.Setenv(EnvEncryptionKey, EncryptionKeyFilePrefix+keyFile)
+
+	c, err := NewFileWithConfig(ctx, FileConfig{Dir: dir, LazyEntryEviction: true, Buckets: 1})
+	if err != nil {
+		t.Fatalf("error creating cache: %v", err)
+	}
+
+	if err = c.Set(ctx, "aws_id_region_cluster", []byte("secret token")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err = os.Stat(keyFile); err != nil {
+		t.Errorf("expected the key file created, got %v", err)
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, ".cache.key")); !os.IsNotExist(err) {
+		t.Errorf("expected no key file under the cache dir, got %v", err)
+	}
+
+	// Neither the identifiers nor the entry are readable.
+	err = filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+
+		if bytes.Contains([]byte(p), []byte("aws_id")) {
+			t.Errorf("expected hashed name, got %s", p)
+		}
+
+		bs, err := os.ReadFile(p)
+		if err == nil && bytes.Contains(bs, []byte("secret token")) {
+			t.Errorf("expected encrypted entry, got plaintext in %s", p)
+		}
+
+		return err
+	})
+	if err != nil {
+		t.Fatalf("error walking: %v", err)
+	}
+
+	// Miss with another key.
+	c2, err := NewFileWithConfig(ctx, FileConfig{
+		Dir:               dir,
+		LazyEntryEviction: true,
+		Buckets:           1,
+		EncryptionKey:     EncryptionKeyFilePrefix + filepath.Join(t.TempDir(), "other.key"),
+	})
+	if err != nil {
+		t.Fatalf("error creating cache: %v", err)
+	}
+
+	if _, err = c2.Get(ctx, "aws_id_region_cluster"); err != ErrEntryNotFound {
+		t.Errorf("expected ErrEntryNotFound with another key, got %v", err)
+	}
+
+	bs, err := c.Get(ctx, "aws_id_region_cluster")
+	if err != nil || string(bs) != "secret token" {
+		t.Errorf("expected secret token, got %q, %v", bs, err)
+	}
+}
+
+func TestTieredCache_encryptionKey(t *testing.T) {
+	var (
+		ctx = context.Background()
+		dir = t.TempDir()
+	)
+
+	t.Setenv(EnvEncryptionKey, "")
+
+	front, err := NewMemory(ctx)
+	if err != nil {
+		t.Fatalf("error creating cache: %v", err)
+	}
+
+	back, err := NewFileWithConfig(ctx, FileConfig{Dir: dir, LazyEntryEviction: true, Buckets: 1})
+	if err != nil {
+		t.Fatalf("error creating cache: %v", err)
+	}
+
+	c, err := NewTiered(ctx, front, back, TieredConfig{SnapshotPath: filepath.Join(dir, "tiered.snapshot")})
+	if err != nil {
+		t.Fatalf("error creating cache: %v", err)
+	}
+
+	if err = c.SetWithTTL(ctx, "k", []byte("v"), time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Keep the key of the snapshot along with the back tier.
+	if _, err = os.Stat(filepath.Join(dir, ".cache.key")); err != nil {
+		t.Errorf("expected the key file under the dir of the back tier, got %v", err)
+	}
+}
+
+func TestLoadEncryptionKeyMaterial_keyring(t *testing.T) {
+	m, err := loadEncryptionKeyMaterial(EncryptionKeyKeyring)
+	if err != nil {
+		t.Skipf("keyring unavailable: %v", err)
+	}
+
+	m2, err := loadEncryptionKeyMaterial(EncryptionKeyKeyring)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !bytes.Equal(m, m2) {
+		t.Error("expected the same key of the keyring")
+	}
+}
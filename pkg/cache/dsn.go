@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpenFunc creates a Cache with the given parsed DSN.
+type OpenFunc = func(ctx context.Context, dsn *url.URL) (Cache, error)
+
+var (
+	openersMu sync.RWMutex
+	openers   = map[string]OpenFunc{}
+)
+
+// Register registers the OpenFunc of the given DSN scheme,
+// it panics if the scheme is registered twice.
+func Register(scheme string, open OpenFunc) {
+	scheme = strings.ToLower(scheme)
+
+	openersMu.Lock()
+	defer openersMu.Unlock()
+
+	if open == nil {
+		panic("cache: register nil open func for " + scheme)
+	}
+
+	if _, exist := openers[scheme]; exist {
+		panic("cache: register twice for " + scheme)
+	}
+
+	openers[scheme] = open
+}
+
+// Schemes returns the sorted registered DSN schemes.
+func Schemes() []string {
+	openersMu.RLock()
+	defer openersMu.RUnlock()
+
+	r := make([]string, 0, len(openers))
+	for k := range openers {
+		r = append(r, k)
+	}
+
+	sort.Strings(r)
+
+	return r
+}
+
+// Open creates a Cache with the given DSN,
+// e.g. "memory://?buckets=64&capacity=1", "file:///path?buckets=12" or "none://".
+func Open(ctx context.Context, dsn string) (Cache, error) {
+	dsn = strings.TrimSpace(dsn)
+	if dsn == "" {
+		return nil, errors.New("blank cache dsn")
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing cache dsn: %w", err)
+	}
+
+	openersMu.RLock()
+	open, exist := openers[strings.ToLower(u.Scheme)]
+	openersMu.RUnlock()
+
+	if !exist {
+		return nil, fmt.Errorf("unknown cache dsn scheme %q, select from %s",
+			u.Scheme, strings.Join(Schemes(), ", "))
+	}
+
+	return open(ctx, u)
+}
+
+// DSNQuery reads the query parameters of a DSN,
+// it records the first error and rejects unknown parameters at Err.
+type DSNQuery struct {
+	values url.Values
+	read   map[string]struct{}
+	err    error
+}
+
+// NewDSNQuery returns a DSNQuery of the given DSN.
+func NewDSNQuery(dsn *url.URL) *DSNQuery {
+	return &DSNQuery{
+		values: dsn.Query(),
+		read:   map[string]struct{}{},
+	}
+}
+
+func (q *DSNQuery) get(name string) (string, bool) {
+	q.read[name] = struct{}{}
+
+	if !q.values.Has(name) {
+		return "", false
+	}
+
+	return q.values.Get(name), true
+}
+
+func (q *DSNQuery) fail(name string, err error) {
+	if q.err == nil {
+		q.err = fmt.Errorf("invalid cache dsn parameter %q: %w", name, err)
+	}
+}
+
+// String reads the string parameter into v if present.
+func (q *DSNQuery) String(name string, v *string) {
+	if s, ok := q.get(name); ok {
+		*v = s
+	}
+}
+
+// Int reads the integer parameter into v if present.
+func (q *DSNQuery) Int(name string, v *int) {
+	if s, ok := q.get(name); ok {
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			q.fail(name, err)
+			return
+		}
+
+		*v = i
+	}
+}
+
+// Bool reads the boolean parameter into v if present,
+// a blank value means true.
+func (q *DSNQuery) Bool(name string, v *bool) {
+	if s, ok := q.get(name); ok {
+		if s == "" {
+			*v = true
+			return
+		}
+
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			q.fail(name, err)
+			return
+		}
+
+		*v = b
+	}
+}
+
+// Duration reads the duration parameter into v if present.
+func (q *DSNQuery) Duration(name string, v *time.Duration) {
+	if s, ok := q.get(name); ok {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			q.fail(name, err)
+			return
+		}
+
+		*v = d
+	}
+}
+
+// Rest returns the parameters not read yet,
+// and treats them as read.
+func (q *DSNQuery) Rest() url.Values {
+	r := url.Values{}
+
+	for k := range q.values {
+		if _, ok := q.read[k]; !ok {
+			r[k] = q.values[k]
+			q.read[k] = struct{}{}
+		}
+	}
+
+	return r
+}
+
+// Err returns the first error of reading,
+// or an error if any parameter is not read.
+func (q *DSNQuery) Err() error {
+	if q.err != nil {
+		return q.err
+	}
+
+	for k := range q.values {
+		if _, ok := q.read[k]; !ok {
+			return fmt.Errorf("unknown cache dsn parameter %q", k)
+		}
+	}
+
+	return nil
+}
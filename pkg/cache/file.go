@@ -1,11 +1,14 @@
 package cache
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"hash/fnv"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -25,7 +28,11 @@ import (
 type FileConfig struct {
 	// Namespace indicates the operating workspace.
 	Namespace string
-	// EntryMaxAge indicates the maximum lifetime of each entry,
+	// Dir indicates the directory to save entries,
+	// default is the data dir.
+	Dir string
+	// EntryMaxAge indicates the lifetime of each entry saved by Set,
+	// entry saved by SetWithTTL lives in the given TTL,
 	// default is 15 mins.
 	EntryMaxAge time.Duration
 	// LazyEntryEviction indicates to evict an expired entry at next peeking,
@@ -35,10 +42,22 @@ type FileConfig struct {
 	// value must be a power of two,
 	// default is 12.
 	Buckets int
+	// EncryptionKey indicates the source of the key to encrypt entries,
+	// select from "file:<path>", "env:<name>", "machine-id", "keyring",
+	// or "none" to save entries in plaintext,
+	// default is the source of EnvEncryptionKey, or a key file under the Dir,
+	// which only protects the copies of the entries, e.g. backups.
+	EncryptionKey string
 }
 
 func (c *FileConfig) Default() {
 	c.Namespace = strings.TrimSpace(c.Namespace)
+
+	c.Dir = strings.TrimSpace(c.Dir)
+	if c.Dir == "" {
+		c.Dir = consts.DataDir()
+	}
+
 	if c.EntryMaxAge == 0 {
 		c.EntryMaxAge = 15 * time.Minute
 	}
@@ -46,6 +65,11 @@ func (c *FileConfig) Default() {
 	if c.Buckets == 0 {
 		c.Buckets = 12
 	}
+
+	c.EncryptionKey = strings.TrimSpace(c.EncryptionKey)
+	if c.EncryptionKey == "" {
+		c.EncryptionKey = defaultEncryptionKey(c.Dir)
+	}
 }
 
 func (c *FileConfig) Validate() error {
@@ -91,10 +115,16 @@ func NewFileWithConfig(ctx context.Context, cfg FileConfig) (Cache, error) {
 		return nil, err
 	}
 
+	// Shared across processes.
+	err = requireKeySecret()
+	if err != nil {
+		return nil, err
+	}
+
 	logger := klog.LoggerWithName(klog.Background(), "cache.file")
 
 	// Prepare directories.
-	dataDir := consts.DataDir()
+	dataDir := cfg.Dir
 	if err = os.MkdirAll(dataDir, dirPerm); err != nil && !os.IsExist(err) {
 		return nil, fmt.Errorf("error creating data dir: %w", err)
 	}
@@ -109,43 +139,76 @@ func NewFileWithConfig(ctx context.Context, cfg FileConfig) (Cache, error) {
 	// Init.
 	underlay := afero.NewBasePathFs(afero.NewOsFs(), dataDir)
 
-	if !cfg.LazyEntryEviction {
-		go func() {
-			_ = wait.PollUntilContextCancel(ctx, 3*time.Minute, true, func(ctx context.Context) (bool, error) {
-				_ = afero.Walk(underlay, pathSep, func(path string, fi os.FileInfo, _ error) error {
-					if fi.IsDir() {
-						return nil
-					}
-
-					if !fi.ModTime().Local().Add(cfg.EntryMaxAge).Before(time.Now()) {
-						return nil
-					}
-
-					err := underlay.Remove(path)
-					if err != nil && !os.IsNotExist(err) {
-						logger.Error(err, "error evicting expired entry", "path", path)
-					}
+	var fcp *entryCipher
 
-					return nil
-				})
+	if cfg.EncryptionKey != EncryptionKeyNone {
+		fcp, err = newEntryCipher(cfg.EncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("error creating cipher: %w", err)
+		}
+	}
 
-				return false, nil
-			})
-		}()
+	lockDir := filepath.Join(dataDir, ".locks")
+	if err = os.MkdirAll(lockDir, dirPerm); err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("error creating lock dir: %w", err)
 	}
 
 	fc := fileCache{
 		logger:     logger,
+		counters:   &counters{},
 		underlay:   underlay,
+		dir:        dataDir,
+		lockDir:    lockDir,
+		cipher:     fcp,
 		bucket:     uint64(cfg.Buckets),
 		namespace:  cfg.Namespace,
 		expiration: cfg.EntryMaxAge,
 		lazyEvict:  cfg.LazyEntryEviction,
 	}
 
+	if !cfg.LazyEntryEviction {
+		go func() {
+			_ = wait.PollUntilContextCancel(ctx, 3*time.Minute, true, func(ctx context.Context) (bool, error) {
+				_, _ = fc.Prune(ctx)
+				return false, nil
+			})
+		}()
+	}
+
 	return fc, nil
 }
 
+func init() {
+	Register("file", openFile)
+}
+
+// openFile opens a filesystem Cache with the given DSN,
+// e.g. "file:///path?namespace=x&max_age=15m&lazy_eviction&buckets=12&encryption_key=env:NAME",
+// the path is optional.
+func openFile(ctx context.Context, dsn *url.URL) (Cache, error) {
+	var cfg FileConfig
+
+	switch {
+	case dsn.Opaque != "":
+		cfg.Dir = dsn.Opaque
+	default:
+		cfg.Dir = dsn.Host + dsn.Path
+	}
+
+	q := NewDSNQuery(dsn)
+	q.String("namespace", &cfg.Namespace)
+	q.Duration("max_age", &cfg.EntryMaxAge)
+	q.Bool("lazy_eviction", &cfg.LazyEntryEviction)
+	q.Int("buckets", &cfg.Buckets)
+	q.String("encryption_key", &cfg.EncryptionKey)
+
+	if err := q.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewFileWithConfig(ctx, cfg)
+}
+
 // MustNewFileWithConfig likes NewFileWithConfig, but panic if error found.
 func MustNewFileWithConfig(ctx context.Context, cfg FileConfig) Cache {
 	n, err := NewFileWithConfig(ctx, cfg)
@@ -159,14 +222,40 @@ func MustNewFileWithConfig(ctx context.Context, cfg FileConfig) Cache {
 // fileCache adapts Cache interface to implement a filesystem cache with afero.Fs.
 type fileCache struct {
 	logger     klog.Logger
+	counters   *counters
 	underlay   afero.Fs
+	dir        string
+	lockDir    string
+	cipher     *entryCipher
 	bucket     uint64
 	namespace  string
 	expiration time.Duration
 	lazyEvict  bool
 }
 
+func (c fileCache) dataDir() string {
+	return c.dir
+}
+
 func (c fileCache) wrapKey(s *string) *string {
+	if c.cipher == nil {
+		return c.plainKey(s)
+	}
+
+	r := filepath.Join(pathSep, c.namespace, *s)
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(r))
+	p := strconv.FormatUint(h.Sum64()%c.bucket, 10)
+
+	// Hash the name to not leak the identifiers within the key.
+	r = filepath.Join(pathSep, p, c.cipher.name(r))
+
+	return &r
+}
+
+// plainKey returns the path of the entry in plaintext format.
+func (c fileCache) plainKey(s *string) *string {
 	r := filepath.Join(pathSep, c.namespace, *s)
 
 	h := fnv.New64a()
@@ -187,6 +276,14 @@ func (c fileCache) Name() string {
 }
 
 func (c fileCache) Set(ctx context.Context, key string, entry []byte) error {
+	return c.SetWithTTL(ctx, key, entry, c.expiration)
+}
+
+func (c fileCache) SetWithTTL(ctx context.Context, key string, entry []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
 	wk := c.wrapKey(&key)
 
 	err := c.underlay.MkdirAll(filepath.Dir(*wk), dirPerm)
@@ -194,19 +291,53 @@ func (c fileCache) Set(ctx context.Context, key string, entry []byte) error {
 		return err
 	}
 
-	err = afero.WriteFile(c.underlay, *wk, entry, filePerm)
-	if err != nil && !os.IsExist(err) {
+	size := len(entry)
+
+	if c.cipher != nil {
+		entry, err = c.seal(key, filepath.Base(*wk), entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Write to a temporary file and rename it,
+	// so that readers never observe a partial entry.
+	f, err := afero.TempFile(c.underlay, filepath.Dir(*wk), ".tmp-*")
+	if err != nil {
+		return wrapFileError(err)
+	}
+
+	tmp := f.Name()
+
+	defer func() {
+		if err != nil {
+			_ = c.underlay.Remove(tmp)
+		}
+	}()
+
+	_, err = f.Write(encodeFileEntry(time.Now().Add(ttl), entry))
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
 		return wrapFileError(err)
 	}
 
-	err = c.underlay.Chtimes(*wk, time.Now(), time.Now())
+	err = c.underlay.Rename(tmp, *wk)
 	if err != nil {
 		return wrapFileError(err)
 	}
 
+	c.counters.set()
+
 	if lg := c.logger.V(5); lg.Enabled() {
 		lg.Info("set",
-			"key", key, "size", humanize.IBytes(uint64(len(entry))))
+			"key", key, "size", humanize.IBytes(uint64(size)), "ttl", ttl)
 	}
 
 	return nil
@@ -215,66 +346,514 @@ func (c fileCache) Set(ctx context.Context, key string, entry []byte) error {
 func (c fileCache) Delete(ctx context.Context, key string) ([]byte, error) {
 	wk := c.wrapKey(&key)
 
-	entry, err := afero.ReadFile(c.underlay, *wk)
+	entry, err := c.evict(*wk)
+	if err == nil && c.cipher != nil {
+		_, entry, err = c.open(key, filepath.Base(*wk), entry)
+		if err != nil {
+			c.logger.Error(err, "error decrypting entry", "key", key)
+
+			entry, err = nil, nil
+		}
+	}
+
+	if c.cipher != nil {
+		// Evict the plaintext entry as well.
+		pe, perr := c.evict(*c.plainKey(&key))
+		if errors.Is(err, ErrEntryNotFound) {
+			entry, err = pe, perr
+		}
+	}
+
 	if err != nil {
-		return nil, wrapFileError(err)
+		return nil, err
+	}
+
+	c.counters.evict(EvictionDeleted)
+
+	if lg := c.logger.V(5); lg.Enabled() {
+		lg.Info("deleted",
+			"key", key, "size", humanize.IBytes(uint64(len(entry))))
+	}
+
+	return entry, nil
+}
+
+// fileEntryMagic prefixes the entry file,
+// which is followed by the expiration in unix nanoseconds, and then the entry,
+// the entry saved without the header expires at its modified time plus the maximum age.
+var fileEntryMagic = []byte{0xff, 'K', 'C', 'F'}
+
+const fileEntryHeaderSize = 12
+
+func encodeFileEntry(exp time.Time, entry []byte) []byte {
+	bs := make([]byte, 0, fileEntryHeaderSize+len(entry))
+	bs = append(bs, fileEntryMagic...)
+	bs = binary.BigEndian.AppendUint64(bs, uint64(exp.UnixNano()))
+
+	return append(bs, entry...)
+}
+
+// decodeFileEntry returns the expiration and the entry of the given file content,
+// the expiration is zero if the entry has been deleted.
+func (c fileCache) decodeFileEntry(bs []byte, fi os.FileInfo) (time.Time, []byte) {
+	if len(bs) < fileEntryHeaderSize || !bytes.HasPrefix(bs, fileEntryMagic) {
+		return fi.ModTime().Add(c.expiration), bs
+	}
+
+	n := binary.BigEndian.Uint64(bs[len(fileEntryMagic):])
+	if n == 0 {
+		return time.Time{}, nil
+	}
+
+	return time.Unix(0, int64(n)), bs[fileEntryHeaderSize:]
+}
+
+// expirationOf returns the expiration of the entry of the given path by reading the header only.
+func (c fileCache) expirationOf(p string, fi os.FileInfo) (time.Time, error) {
+	f, err := c.underlay.Open(p)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	hdr := make([]byte, fileEntryHeaderSize)
+
+	n, err := io.ReadFull(f, hdr)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return time.Time{}, err
+	}
+
+	exp, _ := c.decodeFileEntry(hdr[:n], fi)
+
+	return exp, nil
+}
+
+// evict reads the unexpired entry of the given path, and evicts it.
+func (c fileCache) evict(p string) ([]byte, error) {
+	// The deleted entry waiting for pruning is expired as well.
+	entry, _, err := c.read(p)
+	if err != nil {
+		return nil, err
 	}
 
 	if !c.lazyEvict {
-		err = c.underlay.Chtimes(*wk, time.Now(), time.Now().Add(-c.expiration))
+		// Mark as deleted by zeroing the expiration, which is removed at pruning.
+		err = c.markDeleted(p)
 	} else {
-		err = c.underlay.Remove(*wk)
+		err = c.underlay.Remove(p)
 	}
 
 	if err != nil {
 		return nil, wrapFileError(err)
 	}
 
-	if lg := c.logger.V(5); err == nil && lg.Enabled() {
-		lg.Info("deleted",
-			"key", key, "size", humanize.IBytes(uint64(len(entry))))
+	return entry, nil
+}
+
+func (c fileCache) markDeleted(p string) error {
+	f, err := c.underlay.OpenFile(p, os.O_RDWR, filePerm)
+	if err != nil {
+		return err
 	}
 
-	return entry, nil
+	hdr := make([]byte, fileEntryHeaderSize)
+
+	_, err = io.ReadFull(f, hdr)
+	if err == nil && bytes.HasPrefix(hdr, fileEntryMagic) {
+		_, err = f.WriteAt(make([]byte, 8), int64(len(fileEntryMagic)))
+	} else {
+		// Remove the entry saved without the header directly.
+		err = c.underlay.Remove(p)
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
 }
 
 func (c fileCache) Get(ctx context.Context, key string) ([]byte, error) {
+	entry, _, err := c.GetWithTTL(ctx, key)
+	return entry, err
+}
+
+func (c fileCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
 	wk := c.wrapKey(&key)
 
-	fi, err := c.underlay.Stat(*wk)
+	entry, ttl, err := c.read(*wk)
+	if err == nil && c.cipher != nil {
+		_, entry, err = c.open(key, filepath.Base(*wk), entry)
+		if err != nil {
+			// Treat as missed, the entry may be encrypted by a previous key,
+			// which is overwritten at next saving.
+			c.logger.Error(err, "error decrypting entry", "key", key)
+
+			err = ErrEntryNotFound
+		}
+	}
+
+	if errors.Is(err, ErrEntryNotFound) && c.cipher != nil {
+		entry, ttl, err = c.migrate(ctx, key)
+	}
+
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, ErrEntryNotFound) {
+			c.counters.miss()
 			c.logger.V(5).Info("missed", "key", key)
 		}
 
-		return nil, wrapFileError(err)
+		return nil, 0, err
+	}
+
+	c.counters.hit()
+
+	if lg := c.logger.V(5); lg.Enabled() {
+		lg.Info("hit",
+			"key", key, "size", humanize.IBytes(uint64(len(entry))))
+	}
+
+	return entry, ttl, nil
+}
+
+// read reads the unexpired entry of the given path along with its remaining lifetime.
+func (c fileCache) read(p string) ([]byte, time.Duration, error) {
+	fi, err := c.underlay.Stat(p)
+	if err != nil {
+		return nil, 0, wrapFileError(err)
+	}
+
+	bs, err := afero.ReadFile(c.underlay, p)
+	if err != nil {
+		return nil, 0, wrapFileError(err)
 	}
 
-	if fi.ModTime().Local().Add(c.expiration).Before(time.Now()) {
-		if c.lazyEvict {
-			_ = c.underlay.Remove(*wk)
+	exp, entry := c.decodeFileEntry(bs, fi)
+
+	ttl := time.Until(exp)
+	if ttl <= 0 {
+		if c.lazyEvict && c.underlay.Remove(p) == nil {
+			c.counters.evict(EvictionExpired)
 		}
 
-		c.logger.V(5).Info("missed", "key", key)
+		return nil, 0, ErrEntryNotFound
+	}
+
+	return entry, ttl, nil
+}
+
+// migrate moves the plaintext entry of the given key into the encrypted format,
+// and returns the entry along with its remaining lifetime.
+func (c fileCache) migrate(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	pk := c.plainKey(&key)
 
-		return nil, ErrEntryNotFound
+	_, err := c.underlay.Stat(*pk)
+	if err != nil {
+		return nil, 0, wrapFileError(err)
 	}
 
-	entry, err := afero.ReadFile(c.underlay, *wk)
+	entry, ttl, err := c.read(*pk)
 	if err != nil {
-		if os.IsNotExist(err) {
-			c.logger.V(5).Info("missed", "key", key)
+		if errors.Is(err, ErrEntryNotFound) {
+			c.removePlain(*pk)
 		}
 
-		return nil, wrapFileError(err)
+		return nil, 0, err
 	}
 
-	if lg := c.logger.V(5); err == nil && lg.Enabled() {
-		lg.Info("hit",
-			"key", key, "size", humanize.IBytes(uint64(len(entry))))
+	err = c.SetWithTTL(ctx, key, entry, ttl)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error migrating entry: %w", err)
 	}
 
-	return entry, nil
+	c.removePlain(*pk)
+
+	c.logger.V(5).Info("migrated", "key", key)
+
+	return entry, ttl, nil
+}
+
+// removePlain removes the plaintext entry of the given path,
+// and the emptied parent dirs, which leak the identifiers as well.
+func (c fileCache) removePlain(p string) {
+	_ = c.underlay.Remove(p)
+
+	// Stop at the bucket dir.
+	for d := filepath.Dir(p); strings.Count(d, pathSep) > 1; d = filepath.Dir(d) {
+		if c.underlay.Remove(d) != nil {
+			break
+		}
+	}
+}
+
+// seal encrypts the entry along with its key,
+// the key is kept to list the entries with hashed names.
+func (c fileCache) seal(key, name string, entry []byte) ([]byte, error) {
+	e := make([]byte, 0, binary.MaxVarintLen64+len(key)+len(entry))
+	e = binary.AppendUvarint(e, uint64(len(key)))
+	e = append(e, key...)
+	e = append(e, entry...)
+
+	return c.cipher.seal(name, e)
+}
+
+// open decrypts the sealed entry, and returns its key and entry,
+// it returns an error if the key is not the expected one,
+// an empty expected key skips the verification.
+func (c fileCache) open(key, name string, sealed []byte) (string, []byte, error) {
+	e, err := c.cipher.open(name, sealed)
+	if err != nil {
+		return "", nil, err
+	}
+
+	n, i := binary.Uvarint(e)
+	if i <= 0 || uint64(len(e)-i) < n {
+		return "", nil, errors.New("invalid entry: malformed key")
+	}
+
+	k := string(e[i : i+int(n)])
+	if key != "" && k != key {
+		return "", nil, errors.New("invalid entry: mismatched key")
+	}
+
+	return k, e[i+int(n):], nil
+}
+
+// List implements Lister.
+func (c fileCache) List(ctx context.Context) ([]EntryInfo, error) {
+	ns := filepath.Join(pathSep, c.namespace)
+	if ns != pathSep {
+		ns += pathSep
+	}
+
+	var eis []EntryInfo
+
+	err := c.walk(func(p string, fi os.FileInfo) error {
+		bs, err := afero.ReadFile(c.underlay, p)
+		if err != nil {
+			return nil
+		}
+
+		exp, entry := c.decodeFileEntry(bs, fi)
+
+		ttl := time.Until(exp)
+		if ttl <= 0 {
+			return nil
+		}
+
+		if c.cipher != nil {
+			if k, e, err := c.open("", filepath.Base(p), entry); err == nil {
+				eis = append(eis, EntryInfo{Key: k, Size: len(e), TTL: ttl})
+				return nil
+			}
+		}
+
+		// Plaintext entry is named by its key,
+		// which locates at /<bucket>/<namespace>/<key>.
+		ps := strings.SplitN(strings.TrimPrefix(p, pathSep), pathSep, 2)
+		if len(ps) != 2 {
+			return nil
+		}
+
+		k := strings.TrimPrefix(pathSep+ps[1], ns)
+		if *c.plainKey(&k) != p {
+			return nil
+		}
+
+		eis = append(eis, EntryInfo{Key: k, Size: len(entry), TTL: ttl})
+
+		return nil
+	})
+
+	return eis, err
+}
+
+// tempMaxAge is the age to remove the temporary file,
+// which is leaked by the writer crashed before renaming.
+const tempMaxAge = 10 * time.Minute
+
+// Prune implements Pruner,
+// it removes the leaked temporary files and the legacy lock files as well.
+func (c fileCache) Prune(ctx context.Context) (int, error) {
+	c.pruneTemp()
+	c.pruneLocks()
+
+	var n int
+
+	err := c.walk(func(p string, fi os.FileInfo) error {
+		exp, err := c.expirationOf(p, fi)
+		if err != nil || exp.After(time.Now()) {
+			return nil
+		}
+
+		err = c.underlay.Remove(p)
+		if err != nil && !os.IsNotExist(err) {
+			c.logger.Error(err, "error evicting expired entry", "path", p)
+			return nil
+		}
+
+		// The deleted entry has been counted at deleting.
+		if !exp.IsZero() {
+			c.counters.evict(EvictionExpired)
+		}
+
+		n++
+
+		return nil
+	})
+
+	return n, err
+}
+
+// pruneTemp removes the temporary files older than tempMaxAge.
+func (c fileCache) pruneTemp() {
+	for i := uint64(0); i < c.bucket; i++ {
+		bucketDir := filepath.Join(pathSep, strconv.FormatUint(i, 10))
+
+		_ = afero.Walk(c.underlay, bucketDir, func(p string, fi os.FileInfo, err error) error {
+			if err != nil || fi.IsDir() || !strings.HasPrefix(fi.Name(), ".tmp-") ||
+				time.Since(fi.ModTime()) < tempMaxAge {
+				return nil
+			}
+
+			err = c.underlay.Remove(p)
+			if err != nil && !os.IsNotExist(err) {
+				c.logger.Error(err, "error removing temporary file", "path", p)
+			}
+
+			return nil
+		})
+	}
+}
+
+// pruneLocks removes the legacy lock files of each key older than tempMaxAge,
+// which are not held by others.
+func (c fileCache) pruneLocks() {
+	des, err := os.ReadDir(c.lockDir)
+	if err != nil {
+		return
+	}
+
+	for _, de := range des {
+		// Keep the lock stripes.
+		if i, err := strconv.ParseUint(de.Name(), 10, 64); err == nil && i < lockStripes {
+			continue
+		}
+
+		fi, err := de.Info()
+		if err != nil || fi.IsDir() || time.Since(fi.ModTime()) < tempMaxAge {
+			continue
+		}
+
+		p := filepath.Join(c.lockDir, de.Name())
+
+		f, err := os.OpenFile(p, os.O_RDWR, filePerm)
+		if err != nil {
+			continue
+		}
+
+		ok, _ := tryLockFile(f)
+		if ok {
+			_ = unlockFile(f)
+		}
+
+		// Close ahead, the opened file can not be removed on Windows.
+		_ = f.Close()
+
+		if !ok {
+			continue
+		}
+
+		err = os.Remove(p)
+		if err != nil && !os.IsNotExist(err) {
+			c.logger.Error(err, "error removing legacy lock file", "path", p)
+		}
+	}
+}
+
+// Stats implements StatsGetter,
+// the counters are accumulated within the current process.
+func (c fileCache) Stats(ctx context.Context) (Stats, error) {
+	s := c.counters.stats()
+
+	err := c.walk(func(p string, fi os.FileInfo) error {
+		// Skip the deleted entry waiting for pruning.
+		exp, err := c.expirationOf(p, fi)
+		if err != nil || exp.IsZero() {
+			return nil
+		}
+
+		s.Entries++
+		s.Bytes += fi.Size()
+
+		return nil
+	})
+	if err != nil {
+		return Stats{}, fmt.Errorf("error walking entries: %w", err)
+	}
+
+	return s, nil
+}
+
+// walk calls fn with each file under the bucket dirs,
+// the data dir holds other files, like the key secret.
+func (c fileCache) walk(fn func(p string, fi os.FileInfo) error) error {
+	for i := uint64(0); i < c.bucket; i++ {
+		bucketDir := filepath.Join(pathSep, strconv.FormatUint(i, 10))
+
+		err := afero.Walk(c.underlay, bucketDir, func(p string, fi os.FileInfo, err error) error {
+			if err != nil || fi.IsDir() || strings.HasPrefix(fi.Name(), ".tmp-") {
+				return nil
+			}
+
+			return fn(p, fi)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lockStripes is the number of the lock files,
+// the keys share the lock files by hash, so that the lock dir never grows.
+const lockStripes = 64
+
+// Lock implements Locker,
+// it holds an advisory file lock of the given key,
+// which is visible to other processes sharing the same data dir.
+func (c fileCache) Lock(ctx context.Context, key string) (func(), error) {
+	wk := c.wrapKey(&key)
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(*wk))
+	p := filepath.Join(c.lockDir, strconv.FormatUint(h.Sum64()%lockStripes, 10))
+
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE, filePerm)
+	if err != nil {
+		return nil, fmt.Errorf("error opening lock file: %w", err)
+	}
+
+	err = wait.PollUntilContextCancel(ctx, 50*time.Millisecond, true, func(context.Context) (bool, error) {
+		return tryLockFile(f)
+	})
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("error locking: %w", err)
+	}
+
+	c.logger.V(6).Info("locked", "key", key)
+
+	unlock := func() {
+		_ = unlockFile(f)
+		_ = f.Close()
+
+		c.logger.V(6).Info("unlocked", "key", key)
+	}
+
+	return unlock, nil
 }
 
 func wrapFileError(err error) error {
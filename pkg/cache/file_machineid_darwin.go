@@ -0,0 +1,23 @@
+package cache
+
+import (
+	"errors"
+	"os/exec"
+	"regexp"
+)
+
+var platformUUIDRegexp = regexp.MustCompile(`"IOPlatformUUID" = "([^"]+)"`)
+
+func machineID() (string, error) {
+	out, err := exec.Command("ioreg", "-rd1", "-c", "IOPlatformExpertDevice").Output()
+	if err != nil {
+		return "", err
+	}
+
+	m := platformUUIDRegexp.FindSubmatch(out)
+	if m == nil {
+		return "", errors.New("machine id is not found")
+	}
+
+	return string(m[1]), nil
+}
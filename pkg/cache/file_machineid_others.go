@@ -0,0 +1,24 @@
+//go:build !windows && !darwin
+
+package cache
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+func machineID() (string, error) {
+	for _, p := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id", "/etc/hostid"} {
+		bs, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+
+		if id := strings.TrimSpace(string(bs)); id != "" {
+			return id, nil
+		}
+	}
+
+	return "", errors.New("machine id is not found")
+}
@@ -0,0 +1,19 @@
+package cache
+
+import (
+	"golang.org/x/sys/windows/registry"
+)
+
+func machineID() (string, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE,
+		`SOFTWARE\Microsoft\Cryptography`, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() { _ = k.Close() }()
+
+	id, _, err := k.GetStringValue("MachineGuid")
+
+	return id, err
+}
@@ -0,0 +1,305 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFileCache_expiration(t *testing.T) {
+	for _, ek := range []string{EncryptionKeyNone, ""} {
+		t.Run("encryption key "+ek, func(t *testing.T) {
+			ctx := context.Background()
+
+			c, err := NewFileWithConfig(ctx, FileConfig{
+				Dir:               t.TempDir(),
+				EntryMaxAge:       time.Hour,
+				LazyEntryEviction: true,
+				Buckets:           1,
+				EncryptionKey:     ek,
+			})
+			if err != nil {
+				t.Fatalf("error creating cache: %v", err)
+			}
+
+			fc := c.(fileCache)
+
+			err = c.SetWithTTL(ctx, "short", []byte("v"), 50*time.Millisecond)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			err = c.SetWithTTL(ctx, "long", []byte("v"), 2*time.Hour)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// The modified time is kept as the writing time.
+			key := "long"
+
+			fi, err := fc.underlay.Stat(*fc.wrapKey(&key))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if time.Since(fi.ModTime()) > time.Minute {
+				t.Errorf("expected the modified time of writing, got %s", fi.ModTime())
+			}
+
+			// The TTL is over the maximum age.
+			_, ttl, err := fc.GetWithTTL(ctx, "long")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if ttl <= time.Hour || ttl > 2*time.Hour {
+				t.Errorf("expected ttl about 2h, got %s", ttl)
+			}
+
+			time.Sleep(100 * time.Millisecond)
+
+			if _, err = c.Get(ctx, "short"); err != ErrEntryNotFound {
+				t.Errorf("expected ErrEntryNotFound of the expired entry, got %v", err)
+			}
+
+			es, err := fc.List(ctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(es) != 1 || es[0].Key != "long" || es[0].Size != 1 {
+				t.Errorf("expected the long entry only, got %v", es)
+			}
+		})
+	}
+}
+
+func TestFileCache_delete(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := NewFileWithConfig(ctx, FileConfig{
+		Dir:               t.TempDir(),
+		LazyEntryEviction: true,
+		Buckets:           1,
+		EncryptionKey:     EncryptionKeyNone,
+	})
+	if err != nil {
+		t.Fatalf("error creating cache: %v", err)
+	}
+
+	fc := c.(fileCache)
+	// Mark the deleted entry, which is removed at pruning.
+	fc.lazyEvict = false
+
+	if err = fc.Set(ctx, "k", []byte("v")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err = fc.Delete(ctx, "k"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err = fc.Get(ctx, "k"); err != ErrEntryNotFound {
+		t.Errorf("expected ErrEntryNotFound of the deleted entry, got %v", err)
+	}
+
+	s, err := fc.Stats(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if s.Entries != 0 {
+		t.Errorf("expected no entries, got %d", s.Entries)
+	}
+
+	n, err := fc.Prune(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s, _ = fc.Stats(ctx)
+
+	if n != 1 || s.Evictions[EvictionExpired] != 0 || s.Evictions[EvictionDeleted] != 1 {
+		t.Errorf("expected 1 pruned and counted as deleted, got %d pruned, %v", n, s.Evictions)
+	}
+}
+
+func TestFileCache_legacy(t *testing.T) {
+	var (
+		ctx = context.Background()
+		dir = t.TempDir()
+	)
+
+	c, err := NewFileWithConfig(ctx, FileConfig{
+		Dir:               dir,
+		EntryMaxAge:       time.Hour,
+		LazyEntryEviction: true,
+		Buckets:           1,
+		EncryptionKey:     EncryptionKeyNone,
+	})
+	if err != nil {
+		t.Fatalf("error creating cache: %v", err)
+	}
+
+	fc := c.(fileCache)
+
+	// The entry saved without the header expires at its modified time plus the maximum age.
+	for k, mt := range map[string]time.Time{
+		"fresh":   time.Now().Add(-30 * time.Minute),
+		"expired": time.Now().Add(-2 * time.Hour),
+	} {
+		p := filepath.Join(dir, "0", k)
+
+		if err = os.WriteFile(p, []byte("legacy"), filePerm); err != nil {
+			t.Fatalf("error writing file: %v", err)
+		}
+
+		if err = os.Chtimes(p, mt, mt); err != nil {
+			t.Fatalf("error changing times: %v", err)
+		}
+	}
+
+	bs, ttl, err := fc.GetWithTTL(ctx, "fresh")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(bs) != "legacy" || ttl <= 29*time.Minute || ttl > 30*time.Minute {
+		t.Errorf("expected legacy with ttl about 30m, got %q with %s", bs, ttl)
+	}
+
+	if _, err = c.Get(ctx, "expired"); err != ErrEntryNotFound {
+		t.Errorf("expected ErrEntryNotFound of the expired entry, got %v", err)
+	}
+}
+
+func TestFileCache_pruneTemp(t *testing.T) {
+	var (
+		ctx = context.Background()
+		dir = t.TempDir()
+	)
+
+	c, err := NewFileWithConfig(ctx, FileConfig{
+		Dir:               dir,
+		LazyEntryEviction: true,
+		Buckets:           1,
+		EncryptionKey:     EncryptionKeyNone,
+	})
+	if err != nil {
+		t.Fatalf("error creating cache: %v", err)
+	}
+
+	var (
+		leaked  = filepath.Join(dir, "0", ".tmp-leaked")
+		writing = filepath.Join(dir, "0", ".tmp-writing")
+	)
+
+	for _, p := range []string{leaked, writing} {
+		if err = os.WriteFile(p, []byte("partial"), filePerm); err != nil {
+			t.Fatalf("error writing file: %v", err)
+		}
+	}
+
+	mt := time.Now().Add(-2 * tempMaxAge)
+	if err = os.Chtimes(leaked, mt, mt); err != nil {
+		t.Fatalf("error changing times: %v", err)
+	}
+
+	n, err := c.(Pruner).Prune(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n != 0 {
+		t.Errorf("expected no entries pruned, got %d", n)
+	}
+
+	if _, err = os.Stat(leaked); !os.IsNotExist(err) {
+		t.Errorf("expected the leaked temporary file removed, got %v", err)
+	}
+
+	if _, err = os.Stat(writing); err != nil {
+		t.Errorf("expected the writing temporary file kept, got %v", err)
+	}
+}
+
+func TestFileCache_lockFiles(t *testing.T) {
+	var (
+		ctx = context.Background()
+		dir = t.TempDir()
+	)
+
+	c, err := NewFileWithConfig(ctx, FileConfig{
+		Dir:               dir,
+		LazyEntryEviction: true,
+		Buckets:           1,
+		EncryptionKey:     EncryptionKeyNone,
+	})
+	if err != nil {
+		t.Fatalf("error creating cache: %v", err)
+	}
+
+	// Share the lock files among the keys.
+	for i := 0; i < 10*lockStripes; i++ {
+		unlock, err := c.(Locker).Lock(ctx, "key-"+strconv.Itoa(i))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		unlock()
+	}
+
+	lockDir := filepath.Join(dir, ".locks")
+
+	des, err := os.ReadDir(lockDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(des) > lockStripes {
+		t.Errorf("expected at most %d lock files, got %d", lockStripes, len(des))
+	}
+
+	// Remove the legacy lock files of each key.
+	var (
+		legacy = filepath.Join(lockDir, "9f86d081884c7d65")
+		held   = filepath.Join(lockDir, "2c26b46b68ffc68f")
+	)
+
+	for _, p := range []string{legacy, held} {
+		if err = os.WriteFile(p, nil, filePerm); err != nil {
+			t.Fatalf("error writing file: %v", err)
+		}
+
+		mt := time.Now().Add(-2 * tempMaxAge)
+		if err = os.Chtimes(p, mt, mt); err != nil {
+			t.Fatalf("error changing times: %v", err)
+		}
+	}
+
+	f, err := os.OpenFile(held, os.O_RDWR, filePerm)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Cleanup(func() { _ = f.Close() })
+
+	if ok, err := tryLockFile(f); !ok || err != nil {
+		t.Fatalf("expected locked, got %v, %v", ok, err)
+	}
+
+	if _, err = c.(Pruner).Prune(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err = os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("expected the legacy lock file removed, got %v", err)
+	}
+
+	if _, err = os.Stat(held); err != nil {
+		t.Errorf("expected the held lock file kept, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/seal-io/kubecia/pkg/consts"
+)
+
+const keySecretSize = 32
+
+// EnvKeySecretFile is the environment variable of the key secret file of Digest,
+// which must be the same among the processes sharing a cache, e.g. the replicas over redis,
+// default is the ".secret" file in the data dir.
+const EnvKeySecretFile = "KUBECIA_CACHE_KEY_FILE"
+
+var keySecret struct {
+	sync.Mutex
+
+	v []byte
+	// ephemeral indicates the secret is generated for the process,
+	// which is only used if no shared cache is opened.
+	ephemeral bool
+}
+
+// LoadKeySecret loads the key secret of Digest from the given file, the file is created if not found,
+// the EnvKeySecretFile or the default file is used if blank.
+//
+// Call it before serving to fail early, otherwise, opening the first shared cache loads the key secret.
+func LoadKeySecret(path string) error {
+	keySecret.Lock()
+	defer keySecret.Unlock()
+
+	return loadKeySecret(path)
+}
+
+func loadKeySecret(path string) error {
+	if path == "" {
+		path = os.Getenv(EnvKeySecretFile)
+	}
+
+	if path == "" {
+		path = filepath.Join(consts.DataDir(), ".secret")
+	}
+
+	s, err := loadOrCreateKeySecret(path)
+	if err != nil {
+		return err
+	}
+
+	keySecret.v = s
+	keySecret.ephemeral = false
+
+	return nil
+}
+
+// requireKeySecret loads the default key secret if not loaded yet,
+// the caches shared across processes call it at opening,
+// so that they never split silently by an ephemeral secret.
+func requireKeySecret() error {
+	keySecret.Lock()
+	defer keySecret.Unlock()
+
+	if keySecret.v != nil && !keySecret.ephemeral {
+		return nil
+	}
+
+	err := loadKeySecret("")
+	if err != nil {
+		return fmt.Errorf("error loading cache key: %w", err)
+	}
+
+	return nil
+}
+
+func getKeySecret() ([]byte, error) {
+	keySecret.Lock()
+	defer keySecret.Unlock()
+
+	if keySecret.v != nil {
+		return keySecret.v, nil
+	}
+
+	// No shared cache is opened,
+	// the keys never outlive the process.
+	s := make([]byte, keySecretSize)
+	if _, err := rand.Read(s); err != nil {
+		return nil, fmt.Errorf("error generating key secret: %w", err)
+	}
+
+	keySecret.v = s
+	keySecret.ephemeral = true
+
+	return s, nil
+}
+
+// Digest returns the keyed hash of the given values in hex.
+//
+// The cache key of a token embeds the digest of the full credential set, including the secret,
+// so that a caller presenting a wrong secret never hits the token cached by the others.
+// The hash is an HMAC-SHA256 keyed by the secret of LoadKeySecret,
+// so the key can not be derived without access to the secret.
+func Digest(values ...string) (string, error) {
+	s, err := getKeySecret()
+	if err != nil {
+		return "", err
+	}
+
+	h := hmac.New(sha256.New, s)
+
+	for i := range values {
+		_, _ = h.Write([]byte(values[i]))
+		// Separate the values to avoid ambiguity.
+		_, _ = h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:16]), nil
+}
+
+func loadOrCreateKeySecret(path string) ([]byte, error) {
+	s, err := os.ReadFile(path)
+	if err == nil {
+		if len(s) != keySecretSize {
+			return nil, fmt.Errorf("invalid key secret %s: unexpected size", path)
+		}
+
+		return s, nil
+	}
+
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading key secret: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), dirPerm)
+	if err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("error creating key secret dir: %w", err)
+	}
+
+	s = make([]byte, keySecretSize)
+	if _, err = rand.Read(s); err != nil {
+		return nil, fmt.Errorf("error generating key secret: %w", err)
+	}
+
+	// Publish atomically by hard linking, another process may win the race.
+	f, err := os.CreateTemp(filepath.Dir(path), ".secret-*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating key secret: %w", err)
+	}
+
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	_, err = f.Write(s)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error writing key secret: %w", err)
+	}
+
+	err = os.Link(f.Name(), path)
+	if err != nil {
+		if os.IsExist(err) {
+			return loadOrCreateKeySecret(path)
+		}
+
+		return nil, fmt.Errorf("error publishing key secret: %w", err)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadKeySecret(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		shared = filepath.Join(dir, "shared")
+		other  = filepath.Join(dir, "other")
+	)
+
+	digest := func(path string) string {
+		t.Helper()
+
+		if err := LoadKeySecret(path); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		d, err := Digest("aws", "id", "secret")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		return d
+	}
+
+	// The first replica creates the shared secret, the others load it.
+	d := digest(shared)
+	if d2 := digest(shared); d != d2 {
+		t.Errorf("expected the same digest with the shared secret, got %s and %s", d, d2)
+	}
+
+	if d2 := digest(other); d == d2 {
+		t.Errorf("expected different digest with another secret, got %s", d2)
+	}
+
+	if d2, _ := Digest("aws", "id", "wrong"); d2 == digest(shared) {
+		t.Errorf("expected different digest with a wrong secret, got %s", d2)
+	}
+
+	// Fail loudly instead of falling back to an ephemeral secret.
+	invalid := filepath.Join(dir, "invalid")
+	if err := os.WriteFile(invalid, []byte("short"), 0o600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+
+	if err := LoadKeySecret(invalid); err == nil {
+		t.Error("expected error of the invalid secret, got nil")
+	}
+
+	t.Setenv(EnvKeySecretFile, shared)
+
+	if d2 := digest(""); d != d2 {
+		t.Errorf("expected the same digest with the secret of the environment variable, got %s", d2)
+	}
+}
+
+func TestRequireKeySecret(t *testing.T) {
+	keySecret.Lock()
+	keySecret.v, keySecret.ephemeral = nil, false
+	keySecret.Unlock()
+
+	// Digest with an ephemeral secret if no shared cache is opened.
+	d, err := Digest("aws", "id", "secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "secret")
+	t.Setenv(EnvKeySecretFile, path)
+
+	if err = requireKeySecret(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err = os.Stat(path); err != nil {
+		t.Errorf("expected the key secret created, got %v", err)
+	}
+
+	if d2, _ := Digest("aws", "id", "secret"); d == d2 {
+		t.Errorf("expected the loaded secret replacing the ephemeral one, got %s", d2)
+	}
+
+	// Fail at opening if the key secret can not be loaded.
+	keySecret.Lock()
+	keySecret.v, keySecret.ephemeral = nil, false
+	keySecret.Unlock()
+
+	t.Setenv(EnvKeySecretFile, filepath.Join(path, "invalid"))
+
+	if err = requireKeySecret(); err == nil {
+		t.Error("expected error of the unloadable secret, got nil")
+	}
+}
@@ -0,0 +1,677 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+// Kinds of the Kubernetes object to save entries.
+const (
+	KubernetesKindSecret    = "Secret"
+	KubernetesKindConfigMap = "ConfigMap"
+)
+
+// KubernetesConfig holds the configuration of the Kubernetes cache,
+// entry indexes by key and stores in one bucket,
+// each bucket is a labelled Secret or ConfigMap.
+type KubernetesConfig struct {
+	// Namespace indicates the operating workspace.
+	Namespace string
+	// ObjectNamespace indicates the Kubernetes namespace of the objects,
+	// default is the namespace of the running pod, or "default".
+	ObjectNamespace string
+	// ObjectKind indicates the kind of the objects,
+	// select from "Secret" and "ConfigMap",
+	// default is "Secret".
+	ObjectKind string
+	// ObjectNamePrefix indicates the name prefix of the objects,
+	// default is "kubecia-cache".
+	ObjectNamePrefix string
+	// EntryMaxAge indicates the lifetime of each entry saved by Set,
+	// entry saved by SetWithTTL lives in the given TTL,
+	// default is 15 mins.
+	EntryMaxAge time.Duration
+	// Buckets indicates the bucket number of cache,
+	// default is 8.
+	Buckets int
+	// EncryptionKey indicates the source of the key to encrypt entries,
+	// select from "file:<path>", "env:<name>", "machine-id", "keyring", or "none",
+	// which must be shared by the replicas, e.g. "env:<name>" from a mounted Secret,
+	// default is the source of EnvEncryptionKey, or "none",
+	// the "ConfigMap" kind requires encryption.
+	EncryptionKey string
+}
+
+func (c *KubernetesConfig) Default() {
+	c.Namespace = strings.TrimSpace(c.Namespace)
+
+	c.ObjectNamespace = strings.TrimSpace(c.ObjectNamespace)
+	if c.ObjectNamespace == "" {
+		c.ObjectNamespace = "default"
+
+		bs, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+		if err == nil && len(strings.TrimSpace(string(bs))) != 0 {
+			c.ObjectNamespace = strings.TrimSpace(string(bs))
+		}
+	}
+
+	if c.ObjectKind == "" {
+		c.ObjectKind = KubernetesKindSecret
+	}
+
+	c.ObjectNamePrefix = strings.TrimSpace(c.ObjectNamePrefix)
+	if c.ObjectNamePrefix == "" {
+		c.ObjectNamePrefix = "kubecia-cache"
+	}
+
+	if c.EntryMaxAge == 0 {
+		c.EntryMaxAge = 15 * time.Minute
+	}
+
+	if c.Buckets == 0 {
+		c.Buckets = 8
+	}
+
+	c.EncryptionKey = strings.TrimSpace(c.EncryptionKey)
+	if c.EncryptionKey == "" {
+		c.EncryptionKey = strings.TrimSpace(os.Getenv(EnvEncryptionKey))
+	}
+
+	if c.EncryptionKey == "" {
+		c.EncryptionKey = EncryptionKeyNone
+	}
+}
+
+func (c *KubernetesConfig) Validate() error {
+	switch {
+	case strings.EqualFold(c.ObjectKind, KubernetesKindSecret):
+		c.ObjectKind = KubernetesKindSecret
+	case strings.EqualFold(c.ObjectKind, KubernetesKindConfigMap):
+		c.ObjectKind = KubernetesKindConfigMap
+	default:
+		return fmt.Errorf("invalid object kind %q: select from %s and %s",
+			c.ObjectKind, KubernetesKindSecret, KubernetesKindConfigMap)
+	}
+
+	// ConfigMaps are readable by more subjects than Secrets.
+	if c.ObjectKind == KubernetesKindConfigMap && c.EncryptionKey == EncryptionKeyNone {
+		return errors.New("invalid encryption key: required by ConfigMap kind")
+	}
+
+	if c.EntryMaxAge < 0 {
+		return errors.New("invalid entry max age: negative")
+	}
+
+	if c.Buckets < 0 {
+		return errors.New("invalid buckets: negative")
+	}
+
+	return nil
+}
+
+// NewKubernetesWithClient returns a Kubernetes Cache implementation with given clientset and configuration.
+func NewKubernetesWithClient(ctx context.Context, cli k8s.Interface, cfg KubernetesConfig) (Cache, error) {
+	// Default, validate.
+	cfg.Default()
+
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if cli == nil {
+		return nil, errors.New("invalid client: nil")
+	}
+
+	// Shared across processes.
+	err = requireKeySecret()
+	if err != nil {
+		return nil, err
+	}
+
+	var ecp *entryCipher
+
+	if cfg.EncryptionKey != EncryptionKeyNone {
+		ecp, err = newEntryCipher(cfg.EncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("error creating cipher: %w", err)
+		}
+	}
+
+	var store kubernetesStore = kubernetesSecretStore{
+		cli: cli.CoreV1().Secrets(cfg.ObjectNamespace),
+	}
+	if cfg.ObjectKind == KubernetesKindConfigMap {
+		store = kubernetesConfigMapStore{
+			cli: cli.CoreV1().ConfigMaps(cfg.ObjectNamespace),
+		}
+	}
+
+	kc := kubernetesCache{
+		logger:     klog.LoggerWithName(klog.Background(), "cache.kubernetes"),
+		counters:   &counters{},
+		underlay:   store,
+		cipher:     ecp,
+		prefix:     cfg.ObjectNamePrefix,
+		bucket:     uint64(cfg.Buckets),
+		namespace:  cfg.Namespace,
+		expiration: cfg.EntryMaxAge,
+	}
+
+	return kc, nil
+}
+
+// MustNewKubernetesWithClient likes NewKubernetesWithClient, but panic if error found.
+func MustNewKubernetesWithClient(ctx context.Context, cli k8s.Interface, cfg KubernetesConfig) Cache {
+	n, err := NewKubernetesWithClient(ctx, cli, cfg)
+	if err != nil {
+		panic(fmt.Errorf("error creating kubernetes cache: %w", err))
+	}
+
+	return n
+}
+
+func init() {
+	Register("kubernetes", openKubernetes)
+}
+
+// openKubernetes opens a Kubernetes Cache with the given DSN,
+// e.g. "kubernetes://<object namespace>/<object name prefix>?kind=secret&namespace=x&max_age=15m&buckets=8",
+// the object namespace and name prefix are optional,
+// "kubeconfig" and "context" parameters select the cluster, default is the in-cluster config,
+// "encryption_key" parameter encrypts the entries.
+func openKubernetes(ctx context.Context, dsn *url.URL) (Cache, error) {
+	var (
+		cfg = KubernetesConfig{
+			ObjectNamespace:  dsn.Host,
+			ObjectNamePrefix: strings.Trim(dsn.Path, "/"),
+		}
+		kubeconfig, kubecontext string
+	)
+
+	q := NewDSNQuery(dsn)
+	q.String("namespace", &cfg.Namespace)
+	q.String("kind", &cfg.ObjectKind)
+	q.Duration("max_age", &cfg.EntryMaxAge)
+	q.Int("buckets", &cfg.Buckets)
+	q.String("encryption_key", &cfg.EncryptionKey)
+	q.String("kubeconfig", &kubeconfig)
+	q.String("context", &kubecontext)
+
+	if err := q.Err(); err != nil {
+		return nil, err
+	}
+
+	var (
+		rc  *rest.Config
+		err error
+	)
+
+	if kubeconfig == "" && kubecontext == "" {
+		rc, err = rest.InClusterConfig()
+	}
+
+	if rc == nil {
+		rules := clientcmd.NewDefaultClientConfigLoadingRules()
+		rules.ExplicitPath = kubeconfig
+
+		rc, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			rules,
+			&clientcmd.ConfigOverrides{CurrentContext: kubecontext},
+		).ClientConfig()
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error loading kubernetes config: %w", err)
+	}
+
+	rc.UserAgent = rest.DefaultKubernetesUserAgent() + " kubecia"
+
+	cli, err := k8s.NewForConfig(rc)
+	if err != nil {
+		return nil, fmt.Errorf("error creating kubernetes clientset: %w", err)
+	}
+
+	return NewKubernetesWithClient(ctx, cli, cfg)
+}
+
+const (
+	// kubernetesObjectMaxSize is the maximum data size of a Secret or ConfigMap.
+	kubernetesObjectMaxSize = 1 << 20
+
+	// kubernetesEntryHeaderSize is the size of the header in front of each entry,
+	// which holds the expiration in Unix nanoseconds,
+	// the entry is prefixed with its key to list, and sealed if encrypted.
+	kubernetesEntryHeaderSize = 8
+)
+
+// kubernetesCache adapts Cache interface to implement a cache with Kubernetes Secrets or ConfigMaps.
+type kubernetesCache struct {
+	logger     klog.Logger
+	counters   *counters
+	underlay   kubernetesStore
+	cipher     *entryCipher
+	prefix     string
+	bucket     uint64
+	namespace  string
+	expiration time.Duration
+}
+
+// wrapKey returns the namespaced key, the object name and the data key of the given key.
+func (c kubernetesCache) wrapKey(s *string) (string, string, string) {
+	r := path.Join("/", c.namespace, *s)
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(r))
+	n := c.bucketName(h.Sum64() % c.bucket)
+
+	// Hash the data key to fit the naming rule.
+	if c.cipher != nil {
+		return r, n, c.cipher.name(r)
+	}
+
+	d := sha256.Sum256([]byte(r))
+
+	return r, n, hex.EncodeToString(d[:])
+}
+
+func (c kubernetesCache) bucketName(i uint64) string {
+	return c.prefix + "-" + strconv.FormatUint(i, 10)
+}
+
+func (c kubernetesCache) Close() error {
+	return nil
+}
+
+func (c kubernetesCache) Name() string {
+	return "kubernetes"
+}
+
+func (c kubernetesCache) Set(ctx context.Context, key string, entry []byte) error {
+	return c.SetWithTTL(ctx, key, entry, c.expiration)
+}
+
+func (c kubernetesCache) SetWithTTL(ctx context.Context, key string, entry []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	nk, name, dk := c.wrapKey(&key)
+
+	size := len(entry)
+
+	e, err := c.wrap(nk, dk, entry, time.Now().Add(ttl))
+	if err != nil {
+		return err
+	}
+
+	if len(dk)+len(e) > kubernetesObjectMaxSize {
+		return ErrEntryTooBig
+	}
+
+	var expired int
+
+	err = c.mutate(ctx, name, func(data map[string][]byte) (bool, error) {
+		// Evict expired entries to make room.
+		expired = evictExpiredKubernetesEntries(data)
+
+		data[dk] = e
+
+		if kubernetesDataSize(data) > kubernetesObjectMaxSize {
+			return false, ErrEntryTooBig
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.counters.set()
+
+	for i := 0; i < expired; i++ {
+		c.counters.evict(EvictionExpired)
+	}
+
+	if lg := c.logger.V(5); lg.Enabled() {
+		lg.Info("set",
+			"key", key, "size", humanize.IBytes(uint64(size)), "ttl", ttl)
+	}
+
+	return nil
+}
+
+func (c kubernetesCache) Delete(ctx context.Context, key string) ([]byte, error) {
+	nk, name, dk := c.wrapKey(&key)
+
+	var e []byte
+
+	err := c.mutate(ctx, name, func(data map[string][]byte) (bool, error) {
+		var ok bool
+
+		e, ok = data[dk]
+		if !ok {
+			return false, ErrEntryNotFound
+		}
+
+		delete(data, dk)
+
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.counters.evict(EvictionDeleted)
+
+	_, entry, _, err := c.unwrap(nk, dk, e)
+	if err != nil {
+		return nil, err
+	}
+
+	if lg := c.logger.V(5); lg.Enabled() {
+		lg.Info("deleted",
+			"key", key, "size", humanize.IBytes(uint64(len(entry))))
+	}
+
+	return entry, nil
+}
+
+func (c kubernetesCache) Get(ctx context.Context, key string) ([]byte, error) {
+	entry, _, err := c.GetWithTTL(ctx, key)
+	return entry, err
+}
+
+func (c kubernetesCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	nk, name, dk := c.wrapKey(&key)
+
+	obj, err := c.underlay.get(ctx, name)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			c.counters.miss()
+			c.logger.V(5).Info("missed", "key", key)
+
+			return nil, 0, ErrEntryNotFound
+		}
+
+		return nil, 0, err
+	}
+
+	_, entry, exp, err := c.unwrap(nk, dk, obj.data[dk])
+	if err != nil {
+		if errors.Is(err, ErrEntryNotFound) {
+			c.counters.miss()
+			c.logger.V(5).Info("missed", "key", key)
+		}
+
+		return nil, 0, err
+	}
+
+	c.counters.hit()
+
+	if lg := c.logger.V(5); lg.Enabled() {
+		lg.Info("hit",
+			"key", key, "size", humanize.IBytes(uint64(len(entry))))
+	}
+
+	return entry, time.Until(exp), nil
+}
+
+// List implements Lister.
+func (c kubernetesCache) List(ctx context.Context) ([]EntryInfo, error) {
+	ns := path.Join("/", c.namespace)
+	if ns != "/" {
+		ns += "/"
+	}
+
+	var eis []EntryInfo
+
+	for i := uint64(0); i < c.bucket; i++ {
+		name := c.bucketName(i)
+
+		obj, err := c.underlay.get(ctx, name)
+		if err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+
+			return nil, fmt.Errorf("error getting %s: %w", name, err)
+		}
+
+		for dk := range obj.data {
+			nk, entry, exp, err := c.unwrap("", dk, obj.data[dk])
+			if err != nil || !strings.HasPrefix(nk, ns) {
+				continue
+			}
+
+			eis = append(eis, EntryInfo{
+				Key:  strings.TrimPrefix(nk, ns),
+				Size: len(entry),
+				TTL:  time.Until(exp),
+			})
+		}
+	}
+
+	return eis, nil
+}
+
+// Prune implements Pruner.
+func (c kubernetesCache) Prune(ctx context.Context) (int, error) {
+	var n int
+
+	for i := uint64(0); i < c.bucket; i++ {
+		name := c.bucketName(i)
+
+		var expired int
+
+		err := c.mutate(ctx, name, func(data map[string][]byte) (bool, error) {
+			expired = evictExpiredKubernetesEntries(data)
+			return expired != 0, nil
+		})
+		if err != nil {
+			return n, fmt.Errorf("error pruning %s: %w", name, err)
+		}
+
+		for j := 0; j < expired; j++ {
+			c.counters.evict(EvictionExpired)
+		}
+
+		n += expired
+	}
+
+	return n, nil
+}
+
+// Stats implements StatsGetter,
+// the counters are accumulated within the current process,
+// and the expired entries are excluded.
+func (c kubernetesCache) Stats(ctx context.Context) (Stats, error) {
+	s := c.counters.stats()
+
+	eis, err := c.List(ctx)
+	if err != nil {
+		return Stats{}, fmt.Errorf("error listing entries: %w", err)
+	}
+
+	for i := range eis {
+		s.Entries++
+		s.Bytes += int64(eis[i].Size)
+	}
+
+	return s, nil
+}
+
+// wrap returns the saved data of the given namespaced key and entry.
+func (c kubernetesCache) wrap(nk, dk string, entry []byte, exp time.Time) ([]byte, error) {
+	e := make([]byte, 0, binary.MaxVarintLen64+len(nk)+len(entry))
+	e = binary.AppendUvarint(e, uint64(len(nk)))
+	e = append(e, nk...)
+	e = append(e, entry...)
+
+	if c.cipher != nil {
+		var err error
+
+		e, err = c.cipher.seal(dk, e)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r := make([]byte, kubernetesEntryHeaderSize+len(e))
+	binary.BigEndian.PutUint64(r, uint64(exp.UnixNano()))
+	copy(r[kubernetesEntryHeaderSize:], e)
+
+	return r, nil
+}
+
+// unwrap returns the namespaced key, the unexpired entry and the expiration of the given saved data,
+// it returns ErrEntryNotFound if the key is not the expected one,
+// an empty expected key skips the verification.
+func (c kubernetesCache) unwrap(nk, dk string, e []byte) (string, []byte, time.Time, error) {
+	e, exp := unwrapKubernetesEntry(e)
+	if exp.Before(time.Now()) {
+		return "", nil, time.Time{}, ErrEntryNotFound
+	}
+
+	if c.cipher != nil {
+		var err error
+
+		e, err = c.cipher.open(dk, e)
+		if err != nil {
+			// Treat as missed, the entry may be encrypted by a previous key,
+			// which is overwritten at next saving.
+			c.logger.Error(err, "error decrypting entry")
+
+			return "", nil, time.Time{}, ErrEntryNotFound
+		}
+	}
+
+	n, i := binary.Uvarint(e)
+	if i <= 0 || uint64(len(e)-i) < n {
+		return "", nil, time.Time{}, ErrEntryNotFound
+	}
+
+	k := string(e[i : i+int(n)])
+	if nk != "" && k != nk {
+		return "", nil, time.Time{}, ErrEntryNotFound
+	}
+
+	return k, e[i+int(n):], exp, nil
+}
+
+// mutate applies the given function to the data of the given object,
+// and retries on optimistic concurrency conflicts,
+// the object is created if not found.
+func (c kubernetesCache) mutate(
+	ctx context.Context,
+	name string,
+	fn func(data map[string][]byte) (changed bool, err error),
+) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		obj, err := c.underlay.get(ctx, name)
+		if err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+
+		create := obj == nil
+		if create {
+			obj = &kubernetesObject{
+				ObjectMeta: meta.ObjectMeta{
+					Name: name,
+					Labels: map[string]string{
+						"app.kubernetes.io/managed-by": "kubecia",
+						"kubecia.seal.io/cache":        c.prefix,
+					},
+				},
+			}
+		}
+
+		if obj.data == nil {
+			obj.data = map[string][]byte{}
+		}
+
+		changed, err := fn(obj.data)
+		if err != nil || !changed {
+			return err
+		}
+
+		if create {
+			err = c.underlay.create(ctx, obj)
+			if kerrors.IsAlreadyExists(err) {
+				// Created by others, retry as a conflict.
+				return kerrors.NewConflict(c.underlay.resource(), name, err)
+			}
+		} else {
+			err = c.underlay.update(ctx, obj)
+		}
+
+		return wrapKubernetesError(err)
+	})
+}
+
+func unwrapKubernetesEntry(e []byte) ([]byte, time.Time) {
+	if len(e) < kubernetesEntryHeaderSize {
+		return nil, time.Time{}
+	}
+
+	exp := time.Unix(0, int64(binary.BigEndian.Uint64(e)))
+
+	return e[kubernetesEntryHeaderSize:], exp
+}
+
+// evictExpiredKubernetesEntries deletes the expired entries of the given data,
+// and returns the count.
+func evictExpiredKubernetesEntries(data map[string][]byte) int {
+	var n int
+
+	for k := range data {
+		if _, exp := unwrapKubernetesEntry(data[k]); exp.Before(time.Now()) {
+			delete(data, k)
+			n++
+		}
+	}
+
+	return n
+}
+
+func kubernetesDataSize(data map[string][]byte) int {
+	var s int
+	for k := range data {
+		s += len(k) + len(data[k])
+	}
+
+	return s
+}
+
+func wrapKubernetesError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case kerrors.IsRequestEntityTooLargeError(err):
+		return ErrEntryTooBig
+	case kerrors.IsInvalid(err) && strings.Contains(err.Error(), "Too long"):
+		return ErrEntryTooBig
+	}
+
+	return err
+}
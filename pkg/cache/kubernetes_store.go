@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	corecli "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// kubernetesObject is the common view of a Secret or ConfigMap.
+type kubernetesObject struct {
+	meta.ObjectMeta
+
+	data map[string][]byte
+}
+
+// kubernetesStore holds the actions of the Kubernetes object saving entries.
+type kubernetesStore interface {
+	resource() schema.GroupResource
+	get(ctx context.Context, name string) (*kubernetesObject, error)
+	create(ctx context.Context, obj *kubernetesObject) error
+	update(ctx context.Context, obj *kubernetesObject) error
+}
+
+// kubernetesSecretStore saves entries in the data of Secrets.
+type kubernetesSecretStore struct {
+	cli corecli.SecretInterface
+}
+
+func (s kubernetesSecretStore) resource() schema.GroupResource {
+	return core.Resource("secrets")
+}
+
+func (s kubernetesSecretStore) get(ctx context.Context, name string) (*kubernetesObject, error) {
+	o, err := s.cli.Get(ctx, name, meta.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &kubernetesObject{ObjectMeta: o.ObjectMeta, data: o.Data}, nil
+}
+
+func (s kubernetesSecretStore) create(ctx context.Context, obj *kubernetesObject) error {
+	o := &core.Secret{ObjectMeta: obj.ObjectMeta, Type: core.SecretTypeOpaque, Data: obj.data}
+	_, err := s.cli.Create(ctx, o, meta.CreateOptions{})
+
+	return err
+}
+
+func (s kubernetesSecretStore) update(ctx context.Context, obj *kubernetesObject) error {
+	o := &core.Secret{ObjectMeta: obj.ObjectMeta, Type: core.SecretTypeOpaque, Data: obj.data}
+	_, err := s.cli.Update(ctx, o, meta.UpdateOptions{})
+
+	return err
+}
+
+// kubernetesConfigMapStore saves entries in the binary data of ConfigMaps.
+type kubernetesConfigMapStore struct {
+	cli corecli.ConfigMapInterface
+}
+
+func (s kubernetesConfigMapStore) resource() schema.GroupResource {
+	return core.Resource("configmaps")
+}
+
+func (s kubernetesConfigMapStore) get(ctx context.Context, name string) (*kubernetesObject, error) {
+	o, err := s.cli.Get(ctx, name, meta.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &kubernetesObject{ObjectMeta: o.ObjectMeta, data: o.BinaryData}, nil
+}
+
+func (s kubernetesConfigMapStore) create(ctx context.Context, obj *kubernetesObject) error {
+	o := &core.ConfigMap{ObjectMeta: obj.ObjectMeta, BinaryData: obj.data}
+	_, err := s.cli.Create(ctx, o, meta.CreateOptions{})
+
+	return err
+}
+
+func (s kubernetesConfigMapStore) update(ctx context.Context, obj *kubernetesObject) error {
+	o := &core.ConfigMap{ObjectMeta: obj.ObjectMeta, BinaryData: obj.data}
+	_, err := s.cli.Update(ctx, o, meta.UpdateOptions{})
+
+	return err
+}
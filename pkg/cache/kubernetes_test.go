@@ -0,0 +1,295 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newKubernetesCache(t *testing.T, cfg KubernetesConfig) (kubernetesCache, *fake.Clientset) {
+	t.Helper()
+
+	cli := fake.NewSimpleClientset()
+
+	cfg.ObjectNamespace = "kubecia"
+
+	c, err := NewKubernetesWithClient(context.Background(), cli, cfg)
+	if err != nil {
+		t.Fatalf("error creating cache: %v", err)
+	}
+
+	return c.(kubernetesCache), cli
+}
+
+func TestKubernetesConfig_Validate(t *testing.T) {
+	cases := []struct {
+		name  string
+		cfg   KubernetesConfig
+		valid bool
+	}{
+		{
+			name:  "secret in plaintext",
+			cfg:   KubernetesConfig{ObjectKind: "secret"},
+			valid: true,
+		},
+		{
+			name: "configmap in plaintext",
+			cfg:  KubernetesConfig{ObjectKind: "configmap"},
+		},
+		{
+			name:  "encrypted configmap",
+			cfg:   KubernetesConfig{ObjectKind: "configmap", EncryptionKey: "env:KUBECIA_TEST_KEY"},
+			valid: true,
+		},
+		{
+			name: "unknown kind",
+			cfg:  KubernetesConfig{ObjectKind: "pod"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.Default()
+
+			err := tc.cfg.Validate()
+			if tc.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if !tc.valid && err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestKubernetesCache_buckets(t *testing.T) {
+	t.Setenv("KUBECIA_TEST_KEY", "test")
+
+	for _, kind := range []string{KubernetesKindSecret, KubernetesKindConfigMap} {
+		t.Run(kind, func(t *testing.T) {
+			var (
+				ctx    = context.Background()
+				c, cli = newKubernetesCache(t, KubernetesConfig{
+					ObjectKind:    kind,
+					Buckets:       4,
+					EncryptionKey: "env:KUBECIA_TEST_KEY",
+				})
+			)
+
+			for i := 0; i < 32; i++ {
+				k := "key-" + time.Duration(i).String()
+
+				if err := c.Set(ctx, k, []byte(k)); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			// The entries spread over the labelled buckets.
+			var n int
+
+			if kind == KubernetesKindSecret {
+				l, err := cli.CoreV1().Secrets("kubecia").List(ctx, meta.ListOptions{})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				for i := range l.Items {
+					n++
+
+					checkKubernetesBucket(t, l.Items[i].ObjectMeta, len(l.Items[i].Data))
+				}
+			} else {
+				l, err := cli.CoreV1().ConfigMaps("kubecia").List(ctx, meta.ListOptions{})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				for i := range l.Items {
+					n++
+
+					checkKubernetesBucket(t, l.Items[i].ObjectMeta, len(l.Items[i].BinaryData))
+
+					for _, v := range l.Items[i].BinaryData {
+						if bytes.Contains(v, []byte("key-")) {
+							t.Errorf("expected encrypted entry, got plaintext in %s", l.Items[i].Name)
+						}
+					}
+				}
+			}
+
+			if n != 4 {
+				t.Errorf("expected 4 buckets, got %d", n)
+			}
+
+			es, err := c.List(ctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(es) != 32 {
+				t.Errorf("expected 32 entries, got %d", len(es))
+			}
+
+			bs, err := c.Get(ctx, "key-7ns")
+			if err != nil || string(bs) != "key-7ns" {
+				t.Errorf("expected key-7ns, got %q, %v", bs, err)
+			}
+		})
+	}
+}
+
+func checkKubernetesBucket(t *testing.T, om meta.ObjectMeta, entries int) {
+	t.Helper()
+
+	if !strings.HasPrefix(om.Name, "kubecia-cache-") || om.Labels["kubecia.seal.io/cache"] != "kubecia-cache" {
+		t.Errorf("unexpected bucket %s: %v", om.Name, om.Labels)
+	}
+
+	if entries == 0 {
+		t.Errorf("expected entries in bucket %s", om.Name)
+	}
+}
+
+func TestKubernetesCache_expiration(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		c, cli = newKubernetesCache(t, KubernetesConfig{Namespace: "x", Buckets: 1})
+	)
+
+	if err := c.SetWithTTL(ctx, "short", []byte("v"), 50*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := c.Set(ctx, "long", []byte("v")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Another namespace shares the bucket.
+	other, err := NewKubernetesWithClient(ctx, cli, KubernetesConfig{
+		ObjectNamespace: "kubecia",
+		Namespace:       "y",
+		Buckets:         1,
+	})
+	if err != nil {
+		t.Fatalf("error creating cache: %v", err)
+	}
+
+	if err = other.Set(ctx, "long", []byte("v")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// The expired but not pruned entry is not live.
+	s, err := c.Stats(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if s.Entries != 1 {
+		t.Errorf("expected 1 live entry, got %d", s.Entries)
+	}
+
+	es, err := c.List(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(es) != 1 || es[0].Key != "long" || es[0].TTL <= 14*time.Minute {
+		t.Errorf("expected the long entry only, got %v", es)
+	}
+
+	n, err := c.Prune(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n != 1 {
+		t.Errorf("expected 1 pruned, got %d", n)
+	}
+
+	obj, err := c.underlay.get(ctx, c.bucketName(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(obj.data) != 2 {
+		t.Errorf("expected 2 entries after pruning, got %d", len(obj.data))
+	}
+}
+
+func TestKubernetesCache_conflict(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		c, cli = newKubernetesCache(t, KubernetesConfig{Buckets: 1})
+	)
+
+	if err := c.Set(ctx, "a", []byte("a")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Another replica updates the object between getting and updating.
+	var conflicts atomic.Int32
+
+	cli.PrependReactor("update", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts.Add(1) > 2 {
+			return false, nil, nil
+		}
+
+		obj := action.(k8stesting.UpdateAction).GetObject().(*core.Secret)
+
+		return true, nil, kerrors.NewConflict(core.Resource("secrets"), obj.Name, errors.New("modified"))
+	})
+
+	if err := c.Set(ctx, "b", []byte("b")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if conflicts.Load() != 3 {
+		t.Errorf("expected 2 conflicts and 1 update, got %d updates", conflicts.Load())
+	}
+
+	for _, k := range []string{"a", "b"} {
+		if bs, err := c.Get(ctx, k); err != nil || string(bs) != k {
+			t.Errorf("expected %s, got %q, %v", k, bs, err)
+		}
+	}
+}
+
+func TestKubernetesCache_tooBig(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		c, _ = newKubernetesCache(t, KubernetesConfig{Buckets: 1})
+	)
+
+	if err := c.Set(ctx, "huge", make([]byte, kubernetesObjectMaxSize)); !errors.Is(err, ErrEntryTooBig) {
+		t.Errorf("expected ErrEntryTooBig of the huge entry, got %v", err)
+	}
+
+	// Fill the bucket.
+	half := make([]byte, kubernetesObjectMaxSize/2)
+
+	if err := c.Set(ctx, "a", half); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := c.Set(ctx, "b", half); !errors.Is(err, ErrEntryTooBig) {
+		t.Errorf("expected ErrEntryTooBig of the full bucket, got %v", err)
+	}
+
+	if _, err := c.Get(ctx, "b"); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("expected ErrEntryNotFound of the rejected entry, got %v", err)
+	}
+}
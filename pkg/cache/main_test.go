@@ -0,0 +1,23 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Never touch the key secret of the data dir.
+	dir, err := os.MkdirTemp("", "kubecia-cache-test-")
+	if err != nil {
+		panic(err)
+	}
+
+	_ = os.Setenv(EnvKeySecretFile, filepath.Join(dir, ".secret"))
+
+	code := m.Run()
+
+	_ = os.RemoveAll(dir)
+
+	os.Exit(code)
+}
@@ -2,14 +2,17 @@ package cache
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"net/url"
 	"path"
 	"strings"
 	"time"
 
 	"github.com/allegro/bigcache/v3"
 	"github.com/dustin/go-humanize"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
 )
 
@@ -19,7 +22,8 @@ import (
 type MemoryConfig struct {
 	// Namespace indicates the operating workspace.
 	Namespace string
-	// EntryMaxAge indicates the maximum lifetime of each entry,
+	// EntryMaxAge indicates the lifetime of each entry saved by Set,
+	// entry saved by SetWithTTL lives in the given TTL but no longer than 24 hours,
 	// default is 15 mins.
 	EntryMaxAge time.Duration
 	// LazyEntryEviction indicates to evict an expired entry at next peeking,
@@ -99,7 +103,7 @@ func NewMemoryWithConfig(ctx context.Context, cfg MemoryConfig) (Cache, error) {
 	// For example:
 	//
 	// bigcache.Config{
-	//		LifeWindow:         15 * time.Minute,
+	//		LifeWindow:         24 * time.Hour,    // works as the ceiling, each entry carries its own expiration.
 	//		CleanWindow:        3 * time.Minute,
 	//		Shards:             64,
 	//		MaxEntriesInWindow: 64 * 300,  // works with MaxEntrySize to determinate the cache initialization.
@@ -115,11 +119,14 @@ func NewMemoryWithConfig(ctx context.Context, cfg MemoryConfig) (Cache, error) {
 	//
 	capacity := cfg.BucketCapacity * cfg.Buckets
 
+	lifeWindow := max(cfg.EntryMaxAge, memoryEntryMaxTTL)
+
 	logger := klog.LoggerWithName(klog.Background(), "cache.memory")
+	cs := &counters{}
 
 	underlayCfg := bigcache.Config{
 		Shards:             cfg.Buckets,
-		LifeWindow:         cfg.EntryMaxAge,
+		LifeWindow:         lifeWindow,
 		CleanWindow:        0,
 		MaxEntriesInWindow: cfg.Buckets << 4,
 		MaxEntrySize:       cfg.BucketCapacity << (20 - 4),
@@ -131,11 +138,14 @@ func NewMemoryWithConfig(ctx context.Context, cfg MemoryConfig) (Cache, error) {
 			desc := "unknown"
 			switch reason {
 			case bigcache.Deleted:
-				desc = "deleted"
+				// Counted at deleting, which also evicts the expired entries.
+				desc = EvictionDeleted
 			case bigcache.Expired:
-				desc = "expired"
+				desc = EvictionExpired
+				cs.evict(desc)
 			case bigcache.NoSpace:
-				desc = "nospace"
+				desc = EvictionNoSpace
+				cs.evict(desc)
 			}
 
 			if lg := logger.V(6); lg.Enabled() {
@@ -160,14 +170,52 @@ func NewMemoryWithConfig(ctx context.Context, cfg MemoryConfig) (Cache, error) {
 	}
 
 	mc := memoryCache{
-		logger:    logger,
-		underlay:  underlay,
-		namespace: cfg.Namespace,
+		logger:     logger,
+		counters:   cs,
+		underlay:   underlay,
+		namespace:  cfg.Namespace,
+		expiration: cfg.EntryMaxAge,
+		lifeWindow: lifeWindow,
+	}
+
+	if !cfg.LazyEntryEviction {
+		// The underlay only evicts by the ceiling,
+		// so set up a background looping to evict entries by their own expiration.
+		go func() {
+			_ = wait.PollUntilContextCancel(ctx, 3*time.Minute, false, func(ctx context.Context) (bool, error) {
+				mc.evictExpired()
+				return false, nil
+			})
+		}()
 	}
 
 	return mc, nil
 }
 
+func init() {
+	Register("memory", openMemory)
+}
+
+// openMemory opens an in-memory Cache with the given DSN,
+// e.g. "memory://?namespace=x&max_age=15m&lazy_eviction&buckets=64&capacity=1&lazy_capacity_scale".
+func openMemory(ctx context.Context, dsn *url.URL) (Cache, error) {
+	var cfg MemoryConfig
+
+	q := NewDSNQuery(dsn)
+	q.String("namespace", &cfg.Namespace)
+	q.Duration("max_age", &cfg.EntryMaxAge)
+	q.Bool("lazy_eviction", &cfg.LazyEntryEviction)
+	q.Int("buckets", &cfg.Buckets)
+	q.Int("capacity", &cfg.BucketCapacity)
+	q.Bool("lazy_capacity_scale", &cfg.LazyBucketCapacityScale)
+
+	if err := q.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewMemoryWithConfig(ctx, cfg)
+}
+
 // MustNewMemoryWithConfig likes NewMemoryWithConfig, but panic if error found.
 func MustNewMemoryWithConfig(ctx context.Context, cfg MemoryConfig) Cache {
 	n, err := NewMemoryWithConfig(ctx, cfg)
@@ -178,11 +226,21 @@ func MustNewMemoryWithConfig(ctx context.Context, cfg MemoryConfig) Cache {
 	return n
 }
 
+// memoryEntryMaxTTL is the ceiling of entry lifetime in the in-memory cache.
+const memoryEntryMaxTTL = 24 * time.Hour
+
+// memoryEntryHeaderSize is the size of the header in front of each in-memory entry,
+// which holds the expiration in Unix nanoseconds.
+const memoryEntryHeaderSize = 8
+
 // memoryCache adapts Cache interface to implement an in-memory cache with bigcache.BigCache.
 type memoryCache struct {
-	logger    klog.Logger
-	underlay  *bigcache.BigCache
-	namespace string
+	logger     klog.Logger
+	counters   *counters
+	underlay   *bigcache.BigCache
+	namespace  string
+	expiration time.Duration
+	lifeWindow time.Duration
 }
 
 func (c memoryCache) wrapKey(s *string) *string {
@@ -199,16 +257,32 @@ func (c memoryCache) Name() string {
 }
 
 func (c memoryCache) Set(ctx context.Context, key string, entry []byte) error {
+	return c.SetWithTTL(ctx, key, entry, c.expiration)
+}
+
+func (c memoryCache) SetWithTTL(ctx context.Context, key string, entry []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	ttl = min(ttl, c.lifeWindow)
+
 	wk := c.wrapKey(&key)
 
-	err := c.underlay.Set(*wk, entry)
+	e := make([]byte, memoryEntryHeaderSize+len(entry))
+	binary.BigEndian.PutUint64(e, uint64(time.Now().Add(ttl).UnixNano()))
+	copy(e[memoryEntryHeaderSize:], entry)
+
+	err := c.underlay.Set(*wk, e)
 	if err != nil {
 		return wrapMemoryError(err)
 	}
 
+	c.counters.set()
+
 	if lg := c.logger.V(5); lg.Enabled() {
 		lg.Info("set",
-			"key", key, "size", humanize.IBytes(uint64(len(entry))))
+			"key", key, "size", humanize.IBytes(uint64(len(entry))), "ttl", ttl)
 	}
 
 	return nil
@@ -217,7 +291,7 @@ func (c memoryCache) Set(ctx context.Context, key string, entry []byte) error {
 func (c memoryCache) Delete(ctx context.Context, key string) ([]byte, error) {
 	wk := c.wrapKey(&key)
 
-	entry, err := c.underlay.Get(*wk)
+	e, err := c.underlay.Get(*wk)
 	if err != nil {
 		return nil, wrapMemoryError(err)
 	}
@@ -227,6 +301,10 @@ func (c memoryCache) Delete(ctx context.Context, key string) ([]byte, error) {
 		return nil, wrapMemoryError(err)
 	}
 
+	c.counters.evict(EvictionDeleted)
+
+	entry, _ := unwrapMemoryEntry(e)
+
 	if lg := c.logger.V(5); err == nil && lg.Enabled() {
 		lg.Info("deleted",
 			"key", key, "size", humanize.IBytes(uint64(len(entry))))
@@ -236,23 +314,200 @@ func (c memoryCache) Delete(ctx context.Context, key string) ([]byte, error) {
 }
 
 func (c memoryCache) Get(ctx context.Context, key string) ([]byte, error) {
+	entry, _, err := c.GetWithTTL(ctx, key)
+	return entry, err
+}
+
+func (c memoryCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
 	wk := c.wrapKey(&key)
 
-	entry, err := c.underlay.Get(*wk)
+	e, err := c.underlay.Get(*wk)
 	if err != nil {
 		if errors.Is(err, bigcache.ErrEntryNotFound) {
+			c.counters.miss()
 			c.logger.V(5).Info("missed", "key", key)
 		}
 
-		return nil, wrapMemoryError(err)
+		return nil, 0, wrapMemoryError(err)
+	}
+
+	entry, exp := unwrapMemoryEntry(e)
+
+	ttl := time.Until(exp)
+	if ttl <= 0 {
+		if c.underlay.Delete(*wk) == nil {
+			c.counters.evict(EvictionExpired)
+		}
+
+		c.counters.miss()
+		c.logger.V(5).Info("missed", "key", key)
+
+		return nil, 0, ErrEntryNotFound
 	}
 
+	c.counters.hit()
+
 	if lg := c.logger.V(5); lg.Enabled() {
 		lg.Info("hit",
 			"key", key, "size", humanize.IBytes(uint64(len(entry))))
 	}
 
-	return entry, nil
+	return entry, ttl, nil
+}
+
+// List implements Lister.
+func (c memoryCache) List(ctx context.Context) ([]EntryInfo, error) {
+	var (
+		now = time.Now()
+		ns  = path.Join("/", c.namespace)
+		eis []EntryInfo
+	)
+
+	if ns != "/" {
+		ns += "/"
+	}
+
+	it := c.underlay.Iterator()
+	for it.SetNext() {
+		ei, err := it.Value()
+		if err != nil {
+			continue
+		}
+
+		entry, exp := unwrapMemoryEntry(ei.Value())
+		if !exp.After(now) || !strings.HasPrefix(ei.Key(), ns) {
+			continue
+		}
+
+		eis = append(eis, EntryInfo{
+			Key:  strings.TrimPrefix(ei.Key(), ns),
+			Size: len(entry),
+			TTL:  exp.Sub(now),
+		})
+	}
+
+	return eis, nil
+}
+
+// Stats implements StatsGetter.
+func (c memoryCache) Stats(ctx context.Context) (Stats, error) {
+	s := c.counters.stats()
+
+	it := c.underlay.Iterator()
+	for it.SetNext() {
+		ei, err := it.Value()
+		if err != nil {
+			continue
+		}
+
+		s.Entries++
+		s.Bytes += int64(len(ei.Value()) - memoryEntryHeaderSize)
+	}
+
+	return s, nil
+}
+
+// Prune implements Pruner.
+func (c memoryCache) Prune(ctx context.Context) (int, error) {
+	return c.evictExpired(), nil
+}
+
+// evictExpired removes all expired entries, and returns the removed count.
+func (c memoryCache) evictExpired() int {
+	var (
+		now  = time.Now()
+		keys []string
+	)
+
+	it := c.underlay.Iterator()
+	for it.SetNext() {
+		ei, err := it.Value()
+		if err != nil {
+			continue
+		}
+
+		if _, exp := unwrapMemoryEntry(ei.Value()); !exp.After(now) {
+			keys = append(keys, ei.Key())
+		}
+	}
+
+	var n int
+
+	for i := range keys {
+		if c.underlay.Delete(keys[i]) == nil {
+			c.counters.evict(EvictionExpired)
+			c.logger.V(6).Info("expired", "key", keys[i])
+
+			n++
+		}
+	}
+
+	return n
+}
+
+// dump implements snapshotter.
+func (c memoryCache) dump() []snapshotEntry {
+	var (
+		now = time.Now()
+		es  []snapshotEntry
+	)
+
+	it := c.underlay.Iterator()
+	for it.SetNext() {
+		ei, err := it.Value()
+		if err != nil {
+			continue
+		}
+
+		entry, exp := unwrapMemoryEntry(ei.Value())
+		if !exp.After(now) {
+			continue
+		}
+
+		es = append(es, snapshotEntry{
+			Key:        ei.Key(),
+			Entry:      entry,
+			Expiration: exp,
+		})
+	}
+
+	return es
+}
+
+// restore implements snapshotter.
+func (c memoryCache) restore(es []snapshotEntry) int {
+	var (
+		now = time.Now()
+		n   int
+	)
+
+	for i := range es {
+		if !es[i].Expiration.After(now) {
+			continue
+		}
+
+		e := make([]byte, memoryEntryHeaderSize+len(es[i].Entry))
+		binary.BigEndian.PutUint64(e, uint64(es[i].Expiration.UnixNano()))
+		copy(e[memoryEntryHeaderSize:], es[i].Entry)
+
+		if c.underlay.Set(es[i].Key, e) == nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+// unwrapMemoryEntry returns the entry and its expiration,
+// an entry without valid header is treated as expired.
+func unwrapMemoryEntry(e []byte) ([]byte, time.Time) {
+	if len(e) < memoryEntryHeaderSize {
+		return nil, time.Time{}
+	}
+
+	exp := time.Unix(0, int64(binary.BigEndian.Uint64(e)))
+
+	return e[memoryEntryHeaderSize:], exp
 }
 
 func wrapMemoryError(err error) error {
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+func init() {
+	Register("none", openNone)
+}
+
+// openNone opens a Cache which saves nothing with the given DSN, e.g. "none://",
+// the common parameters are accepted and ignored, so that switching to it only changes the scheme.
+func openNone(_ context.Context, dsn *url.URL) (Cache, error) {
+	var (
+		q         = NewDSNQuery(dsn)
+		namespace string
+		maxAge    time.Duration
+	)
+
+	q.String("namespace", &namespace)
+	q.Duration("max_age", &maxAge)
+
+	if err := q.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewNone(), nil
+}
+
+// NewNone returns a Cache implementation which saves nothing,
+// it is used to disable caching.
+func NewNone() Cache {
+	return noneCache{
+		counters: &counters{},
+	}
+}
+
+type noneCache struct {
+	counters *counters
+}
+
+func (noneCache) Close() error {
+	return nil
+}
+
+func (noneCache) Name() string {
+	return "none"
+}
+
+// Discards implements cachetest.Discarder.
+func (noneCache) Discards() bool {
+	return true
+}
+
+func (noneCache) Set(ctx context.Context, key string, entry []byte) error {
+	return nil
+}
+
+func (noneCache) SetWithTTL(ctx context.Context, key string, entry []byte, ttl time.Duration) error {
+	return nil
+}
+
+func (noneCache) Delete(ctx context.Context, key string) ([]byte, error) {
+	return nil, ErrEntryNotFound
+}
+
+func (c noneCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.counters.miss()
+	return nil, ErrEntryNotFound
+}
+
+// Stats implements StatsGetter, which counts the misses only.
+func (c noneCache) Stats(ctx context.Context) (Stats, error) {
+	return c.counters.stats(), nil
+}
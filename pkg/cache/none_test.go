@@ -0,0 +1,27 @@
+package cache
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOpenNone(t *testing.T) {
+	cases := []struct {
+		dsn   string
+		valid bool
+	}{
+		{dsn: "none://", valid: true},
+		{dsn: "none://?namespace=x&max_age=1m", valid: true},
+		{dsn: "none://?max_age=x", valid: false},
+		{dsn: "none://?unknown=x", valid: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.dsn, func(t *testing.T) {
+			_, err := Open(context.Background(), tc.dsn)
+			if tc.valid != (err == nil) {
+				t.Errorf("expected valid %v, got error %v", tc.valid, err)
+			}
+		})
+	}
+}
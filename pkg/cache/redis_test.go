@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newRedisCache(t *testing.T, mr *miniredis.Miniredis, query string) redisCache {
+	t.Helper()
+
+	c, err := Open(context.Background(), "redis://"+mr.Addr()+"/0?"+query)
+	if err != nil {
+		t.Fatalf("error opening cache: %v", err)
+	}
+
+	t.Cleanup(func() { _ = c.Close() })
+
+	return c.(redisCache)
+}
+
+func TestRedisCache_ttl(t *testing.T) {
+	var (
+		ctx = context.Background()
+		mr  = miniredis.RunT(t)
+		c   = newRedisCache(t, mr, "namespace=x&max_age=1m")
+	)
+
+	if err := c.Set(ctx, "default", []byte("v")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := c.SetWithTTL(ctx, "short", []byte("v"), 10*time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := mr.TTL("kubecia:x:default"); got != time.Minute {
+		t.Errorf("expected the maximum age as ttl, got %s", got)
+	}
+
+	_, ttl, err := c.GetWithTTL(ctx, "short")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ttl != 10*time.Second {
+		t.Errorf("expected ttl 10s, got %s", ttl)
+	}
+
+	mr.FastForward(11 * time.Second)
+
+	if _, err = c.Get(ctx, "short"); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("expected ErrEntryNotFound of the expired entry, got %v", err)
+	}
+
+	if _, err = c.Get(ctx, "default"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRedisCache_delete(t *testing.T) {
+	var (
+		ctx = context.Background()
+		mr  = miniredis.RunT(t)
+		c   = newRedisCache(t, mr, "")
+	)
+
+	if err := c.Set(ctx, "k", []byte("v")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// GET and DEL in one transaction.
+	bs, err := c.Delete(ctx, "k")
+	if err != nil || string(bs) != "v" {
+		t.Fatalf("expected the deleted entry v, got %q, %v", bs, err)
+	}
+
+	if mr.Exists("kubecia:k") {
+		t.Error("expected the entry deleted")
+	}
+
+	if _, err = c.Delete(ctx, "k"); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("expected ErrEntryNotFound of the deleted entry, got %v", err)
+	}
+
+	s, err := c.Stats(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if s.Evictions[EvictionDeleted] != 1 {
+		t.Errorf("expected 1 deleted eviction, got %d", s.Evictions[EvictionDeleted])
+	}
+}
+
+func TestRedisCache_list(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		mr    = miniredis.RunT(t)
+		c     = newRedisCache(t, mr, "namespace=x")
+		other = newRedisCache(t, mr, "namespace=y")
+	)
+
+	// More than one SCAN page.
+	for i := 0; i < 150; i++ {
+		if err := c.Set(ctx, "k"+time.Duration(i).String(), []byte("v")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if err := other.Set(ctx, "k", []byte("v")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	unlock, err := c.Lock(ctx, "k0s")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer unlock()
+
+	es, err := c.List(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Exclude the entries of other namespaces and the locks.
+	if len(es) != 150 {
+		t.Errorf("expected 150 entries, got %d", len(es))
+	}
+
+	for i := range es {
+		if es[i].Size != 1 || es[i].TTL <= 0 {
+			t.Errorf("unexpected entry: %v", es[i])
+		}
+	}
+}
+
+func TestRedisCache_lock(t *testing.T) {
+	var (
+		ctx = context.Background()
+		mr  = miniredis.RunT(t)
+		// Replicas sharing one Redis.
+		a = newRedisCache(t, mr, "lock_lease=10s")
+		b = newRedisCache(t, mr, "lock_lease=10s")
+	)
+
+	unlockA, err := a.Lock(ctx, "k")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// SETNX fails while held by another replica.
+	tctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+
+	if _, err = b.Lock(tctx, "k"); err == nil {
+		t.Fatal("expected error of locking the held lock, got nil")
+	}
+
+	// The lease avoids leaking the lock of a crashed holder.
+	mr.FastForward(11 * time.Second)
+
+	unlockB, err := b.Lock(ctx, "k")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The stale holder never releases the lock held by others.
+	unlockA()
+
+	if !mr.Exists("kubecia:k:lock") {
+		t.Fatal("expected the lock still held")
+	}
+
+	unlockB()
+
+	if mr.Exists("kubecia:k:lock") {
+		t.Error("expected the lock released")
+	}
+}
+
+func TestRedisCache_replicas(t *testing.T) {
+	var (
+		ctx = context.Background()
+		mr  = miniredis.RunT(t)
+		a   = newRedisCache(t, mr, "namespace=x")
+		b   = newRedisCache(t, mr, "namespace=x")
+	)
+
+	// Replicas digest the same key with the shared key secret file.
+	var (
+		shared = filepath.Join(t.TempDir(), "cache.key")
+		key    = func(path string) string {
+			t.Helper()
+
+			if err := LoadKeySecret(path); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			d, err := Digest("aws", "id", "secret", "region", "cluster", "")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			return "aws_id_region_cluster_self_" + d
+		}
+	)
+
+	if err := a.Set(ctx, key(shared), []byte("token")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bs, err := b.Get(ctx, key(shared))
+	if err != nil || string(bs) != "token" {
+		t.Errorf("expected token of another replica, got %q, %v", bs, err)
+	}
+
+	// Miss with an unshared key secret.
+	if _, err = b.Get(ctx, key(filepath.Join(t.TempDir(), "cache.key"))); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("expected ErrEntryNotFound with another key secret, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Eviction reasons of the Stats.
+const (
+	EvictionExpired = "expired"
+	EvictionDeleted = "deleted"
+	EvictionNoSpace = "nospace"
+)
+
+// Stats holds the statistics of a Cache,
+// the counters are accumulated since the Cache created.
+type Stats struct {
+	// Hits is the count of reading an existing entry.
+	Hits int64 `json:"hits"`
+	// Misses is the count of reading a missing or expired entry.
+	Misses int64 `json:"misses"`
+	// Sets is the count of saving an entry.
+	Sets int64 `json:"sets"`
+	// Evictions is the count of evicted entries by reason.
+	Evictions map[string]int64 `json:"evictions"`
+	// Entries is the count of existing entries,
+	// which may include the expired but not evicted ones if the cache can not tell.
+	Entries int64 `json:"entries"`
+	// Bytes is the total size of existing entries.
+	Bytes int64 `json:"bytes"`
+}
+
+// StatsGetter holds the action of reporting the statistics,
+// which is implemented by the Cache accounting its operations.
+type StatsGetter interface {
+	// Stats returns the statistics of the Cache.
+	Stats(ctx context.Context) (Stats, error)
+}
+
+// counters accumulates the operations of a Cache.
+type counters struct {
+	hits, misses, sets        atomic.Int64
+	expired, deleted, nospace atomic.Int64
+}
+
+func (c *counters) hit() {
+	c.hits.Add(1)
+}
+
+func (c *counters) miss() {
+	c.misses.Add(1)
+}
+
+func (c *counters) set() {
+	c.sets.Add(1)
+}
+
+func (c *counters) evict(reason string) {
+	switch reason {
+	case EvictionExpired:
+		c.expired.Add(1)
+	case EvictionDeleted:
+		c.deleted.Add(1)
+	case EvictionNoSpace:
+		c.nospace.Add(1)
+	}
+}
+
+// stats returns the Stats of the counters, entries and bytes are left zero.
+func (c *counters) stats() Stats {
+	return Stats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Sets:   c.sets.Load(),
+		Evictions: map[string]int64{
+			EvictionExpired: c.expired.Load(),
+			EvictionDeleted: c.deleted.Load(),
+			EvictionNoSpace: c.nospace.Load(),
+		},
+	}
+}
@@ -0,0 +1,463 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/consts"
+)
+
+// TieredConfig holds the configuration of the tiered cache.
+type TieredConfig struct {
+	// SnapshotPath indicates the file to snapshot the front tier at closing,
+	// which is reloaded at next creating,
+	// blank to disable.
+	SnapshotPath string
+	// EncryptionKey indicates the source of the key to encrypt the snapshot,
+	// select from "file:<path>", "env:<name>", "machine-id", "keyring", or "none",
+	// default is the source of EnvEncryptionKey, or a key file under the dir of the back tier,
+	// or under the data dir if the back tier is not saved in the filesystem.
+	EncryptionKey string
+}
+
+func (c *TieredConfig) Default() {
+	c.SnapshotPath = strings.TrimSpace(c.SnapshotPath)
+
+	c.EncryptionKey = strings.TrimSpace(c.EncryptionKey)
+	if c.EncryptionKey == "" {
+		c.EncryptionKey = defaultEncryptionKey(consts.DataDir())
+	}
+}
+
+// NewTiered returns a Cache implementation reading through the front tier to the back tier,
+// and writing through both tiers,
+// the returned Cache closes both tiers.
+//
+// If the front tier is an in-memory cache and the snapshot path is configured,
+// the front tier is snapshotted at closing, and reloaded at next creating.
+func NewTiered(ctx context.Context, front, back Cache, cfg TieredConfig) (Cache, error) {
+	// Keep the key along with the entries of the back tier.
+	if d, ok := back.(dataDirGetter); ok && strings.TrimSpace(cfg.EncryptionKey) == "" {
+		cfg.EncryptionKey = defaultEncryptionKey(d.dataDir())
+	}
+
+	cfg.Default()
+
+	if front == nil || back == nil {
+		return nil, errors.New("invalid tiers: nil")
+	}
+
+	tc := tieredCache{
+		logger:       klog.LoggerWithName(klog.Background(), "cache.tiered"),
+		counters:     &counters{},
+		front:        front,
+		back:         back,
+		snapshotPath: cfg.SnapshotPath,
+	}
+
+	if _, ok := front.(snapshotter); !ok || tc.snapshotPath == "" {
+		tc.snapshotPath = ""
+		return tc, nil
+	}
+
+	if cfg.EncryptionKey != EncryptionKeyNone {
+		ecp, err := newEntryCipher(cfg.EncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("error creating cipher: %w", err)
+		}
+
+		tc.cipher = ecp
+	}
+
+	// Warm start from the snapshot.
+	err := tc.loadSnapshot()
+	if err != nil {
+		tc.logger.Error(err, "error loading snapshot, starting cold", "path", tc.snapshotPath)
+	}
+
+	return tc, nil
+}
+
+func init() {
+	Register("tiered", openTiered)
+}
+
+// openTiered opens a tiered Cache with the given DSN,
+// e.g. "tiered://?front=memory://&back=file://&snapshot=/path&encryption_key=env:NAME",
+// the nested DSN must be escaped, the front is "memory://" and the back is "file://" by default,
+// the snapshot is a file under the data dir by default, blank to disable.
+func openTiered(ctx context.Context, dsn *url.URL) (Cache, error) {
+	var (
+		frontDSN = "memory://"
+		backDSN  = "file://"
+		cfg      = TieredConfig{
+			SnapshotPath: filepath.Join(consts.DataDir(), "tiered.snapshot"),
+		}
+	)
+
+	q := NewDSNQuery(dsn)
+	q.String("front", &frontDSN)
+	q.String("back", &backDSN)
+	q.String("snapshot", &cfg.SnapshotPath)
+	q.String("encryption_key", &cfg.EncryptionKey)
+
+	if err := q.Err(); err != nil {
+		return nil, err
+	}
+
+	front, err := Open(ctx, frontDSN)
+	if err != nil {
+		return nil, fmt.Errorf("error opening front tier: %w", err)
+	}
+
+	back, err := Open(ctx, backDSN)
+	if err != nil {
+		_ = front.Close()
+		return nil, fmt.Errorf("error opening back tier: %w", err)
+	}
+
+	c, err := NewTiered(ctx, front, back, cfg)
+	if err != nil {
+		_ = front.Close()
+		_ = back.Close()
+
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// dataDirGetter gets the data dir of a Cache saved in the filesystem.
+type dataDirGetter interface {
+	dataDir() string
+}
+
+type (
+	// snapshotter holds the actions of snapshotting a Cache.
+	snapshotter interface {
+		// dump returns the unexpired entries.
+		dump() []snapshotEntry
+		// restore saves the given entries, skips the expired ones,
+		// and returns the restored count.
+		restore(entries []snapshotEntry) int
+	}
+
+	// snapshotEntry is the entry of snapshot,
+	// the key is the key saved in the underlay.
+	snapshotEntry struct {
+		Key        string
+		Entry      []byte
+		Expiration time.Time
+	}
+)
+
+// tieredCache adapts Cache interface to implement a read-through and write-through cache with two tiers.
+type tieredCache struct {
+	logger       klog.Logger
+	counters     *counters
+	front        Cache
+	back         Cache
+	cipher       *entryCipher
+	snapshotPath string
+}
+
+func (c tieredCache) Close() error {
+	if c.snapshotPath != "" {
+		err := c.saveSnapshot()
+		if err != nil {
+			c.logger.Error(err, "error saving snapshot", "path", c.snapshotPath)
+		}
+	}
+
+	return errors.Join(c.front.Close(), c.back.Close())
+}
+
+func (c tieredCache) Name() string {
+	return "tiered"
+}
+
+func (c tieredCache) Set(ctx context.Context, key string, entry []byte) error {
+	err := errors.Join(
+		c.back.Set(ctx, key, entry),
+		c.front.Set(ctx, key, entry))
+	if err == nil {
+		c.counters.set()
+	}
+
+	return err
+}
+
+func (c tieredCache) SetWithTTL(ctx context.Context, key string, entry []byte, ttl time.Duration) error {
+	err := errors.Join(
+		c.back.SetWithTTL(ctx, key, entry, ttl),
+		c.front.SetWithTTL(ctx, key, entry, ttl))
+	if err == nil {
+		c.counters.set()
+	}
+
+	return err
+}
+
+func (c tieredCache) Delete(ctx context.Context, key string) ([]byte, error) {
+	fe, ferr := c.front.Delete(ctx, key)
+	be, berr := c.back.Delete(ctx, key)
+
+	switch {
+	case ferr == nil:
+		c.counters.evict(EvictionDeleted)
+		return fe, nil
+	case berr == nil:
+		c.counters.evict(EvictionDeleted)
+		return be, nil
+	case errors.Is(ferr, ErrEntryNotFound):
+		return nil, berr
+	}
+
+	return nil, ferr
+}
+
+func (c tieredCache) Get(ctx context.Context, key string) ([]byte, error) {
+	entry, _, err := c.GetWithTTL(ctx, key)
+	return entry, err
+}
+
+func (c tieredCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	entry, ttl, err := getWithTTL(ctx, c.front, key)
+	if err == nil {
+		c.counters.hit()
+		return entry, ttl, nil
+	}
+
+	if !errors.Is(err, ErrEntryNotFound) {
+		return nil, 0, err
+	}
+
+	entry, ttl, err = getWithTTL(ctx, c.back, key)
+	if err != nil {
+		if errors.Is(err, ErrEntryNotFound) {
+			c.counters.miss()
+		}
+
+		return nil, 0, err
+	}
+
+	c.counters.hit()
+
+	// Promote to the front tier.
+	if ttl > 0 {
+		err = c.front.SetWithTTL(ctx, key, entry, ttl)
+	} else {
+		err = c.front.Set(ctx, key, entry)
+	}
+
+	if err != nil {
+		c.logger.Error(err, "error promoting entry", "key", key)
+	}
+
+	return entry, ttl, nil
+}
+
+// List implements Lister with the union of both tiers.
+func (c tieredCache) List(ctx context.Context) ([]EntryInfo, error) {
+	var (
+		eis  []EntryInfo
+		seen = map[string]struct{}{}
+	)
+
+	for _, t := range []Cache{c.front, c.back} {
+		l, ok := t.(Lister)
+		if !ok {
+			continue
+		}
+
+		r, err := l.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range r {
+			if _, ok := seen[r[i].Key]; ok {
+				continue
+			}
+
+			seen[r[i].Key] = struct{}{}
+			eis = append(eis, r[i])
+		}
+	}
+
+	return eis, nil
+}
+
+// Prune implements Pruner with both tiers.
+func (c tieredCache) Prune(ctx context.Context) (int, error) {
+	var n int
+
+	for _, t := range []Cache{c.front, c.back} {
+		p, ok := t.(Pruner)
+		if !ok {
+			continue
+		}
+
+		r, err := p.Prune(ctx)
+		if err != nil {
+			return n, err
+		}
+
+		n += r
+	}
+
+	return n, nil
+}
+
+// Stats implements StatsGetter,
+// the hits, misses, sets and deletions are counted at the tiered level,
+// the expired and no space evictions, entries and bytes are reported by the back tier if possible,
+// otherwise by the front tier.
+func (c tieredCache) Stats(ctx context.Context) (Stats, error) {
+	s := c.counters.stats()
+
+	for _, t := range []Cache{c.back, c.front} {
+		g, ok := t.(StatsGetter)
+		if !ok {
+			continue
+		}
+
+		ts, err := g.Stats(ctx)
+		if err != nil {
+			return Stats{}, err
+		}
+
+		s.Evictions[EvictionExpired] = ts.Evictions[EvictionExpired]
+		s.Evictions[EvictionNoSpace] = ts.Evictions[EvictionNoSpace]
+		s.Entries = ts.Entries
+		s.Bytes = ts.Bytes
+
+		break
+	}
+
+	return s, nil
+}
+
+// Lock implements Locker with the back tier,
+// which is the one shared across processes.
+func (c tieredCache) Lock(ctx context.Context, key string) (func(), error) {
+	if l, ok := c.back.(Locker); ok {
+		return l.Lock(ctx, key)
+	}
+
+	return func() {}, nil
+}
+
+// getWithTTL reads the entry of the given Cache,
+// the returned lifetime is zero if the Cache is not a TTLGetter.
+func getWithTTL(ctx context.Context, c Cache, key string) ([]byte, time.Duration, error) {
+	if g, ok := c.(TTLGetter); ok {
+		return g.GetWithTTL(ctx, key)
+	}
+
+	entry, err := c.Get(ctx, key)
+
+	return entry, 0, err
+}
+
+// snapshotName is the authenticated name of the encrypted snapshot.
+const snapshotName = "snapshot"
+
+func (c tieredCache) saveSnapshot() error {
+	es := c.front.(snapshotter).dump()
+
+	var buf bytes.Buffer
+
+	err := gob.NewEncoder(&buf).Encode(es)
+	if err != nil {
+		return fmt.Errorf("error encoding snapshot: %w", err)
+	}
+
+	bs := buf.Bytes()
+	if c.cipher != nil {
+		bs, err = c.cipher.seal(snapshotName, bs)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.MkdirAll(filepath.Dir(c.snapshotPath), dirPerm)
+	if err != nil && !os.IsExist(err) {
+		return fmt.Errorf("error creating snapshot dir: %w", err)
+	}
+
+	// Write to a temporary file and rename it,
+	// so that the next start never reads a partial snapshot.
+	f, err := os.CreateTemp(filepath.Dir(c.snapshotPath), ".snapshot-*")
+	if err != nil {
+		return fmt.Errorf("error creating snapshot: %w", err)
+	}
+
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	_, err = f.Write(bs)
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+
+	err = os.Rename(f.Name(), c.snapshotPath)
+	if err != nil {
+		return fmt.Errorf("error publishing snapshot: %w", err)
+	}
+
+	c.logger.V(4).Info("saved snapshot", "path", c.snapshotPath, "entries", len(es))
+
+	return nil
+}
+
+func (c tieredCache) loadSnapshot() error {
+	bs, err := os.ReadFile(c.snapshotPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("error reading snapshot: %w", err)
+	}
+
+	// Consume the snapshot once, it is rewritten at closing.
+	defer func() { _ = os.Remove(c.snapshotPath) }()
+
+	if c.cipher != nil {
+		bs, err = c.cipher.open(snapshotName, bs)
+		if err != nil {
+			return fmt.Errorf("error decrypting snapshot: %w", err)
+		}
+	}
+
+	var es []snapshotEntry
+
+	err = gob.NewDecoder(bytes.NewReader(bs)).Decode(&es)
+	if err != nil {
+		return fmt.Errorf("error decoding snapshot: %w", err)
+	}
+
+	n := c.front.(snapshotter).restore(es)
+
+	c.logger.V(4).Info("loaded snapshot", "path", c.snapshotPath, "entries", n, "expired", len(es)-n)
+
+	return nil
+}
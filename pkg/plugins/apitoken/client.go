@@ -0,0 +1,160 @@
+package apitoken
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/apis"
+	"github.com/seal-io/kubecia/pkg/bytespool"
+	"github.com/seal-io/kubecia/pkg/cache"
+	"github.com/seal-io/kubecia/pkg/consts"
+	"github.com/seal-io/kubecia/pkg/token"
+	"github.com/seal-io/kubecia/pkg/version"
+)
+
+// Client gets the token of the Provider from the central service or locally.
+type Client struct {
+	Provider *Provider
+
+	Socket   string
+	Cache    string
+	APIToken string
+	Cluster  string
+	BaseURL  string
+}
+
+func (cli *Client) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&cli.Socket, "socket", consts.SocketPath(),
+		"Socket path or URL of the central service, e.g. /var/run/kubecia.sock or https://kubecia.example.com:8443")
+	flags.StringVar(&cli.Cache, "cache", "file://",
+		"Cache DSN, e.g. file:///path?buckets=12, memory:// or none://")
+	flags.StringVar(&cli.APIToken, "api-token", "", cli.Provider.DisplayName+" personal access token *")
+	flags.StringVar(&cli.Cluster, "cluster", "", cli.Provider.ClusterDescription+" *")
+	flags.StringVar(&cli.BaseURL, "base-url", cli.Provider.DefaultBaseURL,
+		cli.Provider.DisplayName+" API base URL, which is only supported when getting locally")
+}
+
+func (cli *Client) GetToken(ctx context.Context) (*token.Token, error) {
+	logger := klog.LoggerWithName(klog.Background(), cli.Provider.Namespace)
+
+	if apis.Served(cli.Socket) {
+		// The central service requests the default endpoint only,
+		// never replace the custom one silently.
+		if strings.TrimSuffix(cli.BaseURL, "/") != strings.TrimSuffix(cli.Provider.DefaultBaseURL, "/") {
+			return nil, errors.New("invalid options: --base-url is not supported by the central service, " +
+				"specify --socket=\"\" to get locally")
+		}
+
+		logger.V(6).Info("getting from central service")
+
+		tk, err := cli.GetTokenByHTTP(ctx, apis.Client(cli.Socket))
+		if err == nil {
+			logger.V(6).Info("got from central service")
+
+			return tk, nil
+		}
+
+		var rce remoteCallError
+		if !errors.As(err, &rce) {
+			return nil, err
+		}
+
+		logger.Error(err, "error getting from central service, try getting locally")
+	} else {
+		logger.V(6).Info("getting locally")
+	}
+
+	tk, err := cli.getToken(ctx)
+	if err == nil {
+		logger.V(6).Info("got locally")
+
+		return tk, nil
+	}
+
+	return nil, fmt.Errorf("error getting token locally: %w", err)
+}
+
+func (cli *Client) GetTokenByHTTP(ctx context.Context, httpc *http.Client) (*token.Token, error) {
+	url := apis.Route(cli.Provider.Namespace, cli.Cluster)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, wrapRemoteCallError(fmt.Errorf("error creating remote request: %w", err))
+	}
+
+	req.Header.Set("Authorization", "Bearer "+cli.APIToken)
+
+	req.Header.Set("User-Agent", version.Get())
+	req.Header.Set("X-KubeCIA-DeCapsuled", "true")
+
+	resp, err := httpc.Do(req)
+	if err != nil {
+		return nil, wrapRemoteCallError(fmt.Errorf("error making remote request: %w", err))
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apis.ResponseError(resp)
+	}
+
+	buf := bytespool.GetBuffer()
+	defer bytespool.Put(buf)
+
+	_, err = io.Copy(buf, resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error copying response body: %w", err)
+	}
+
+	var tk token.Token
+	if err = tk.UnmarshalJSON(buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("error unmarshalling requested token: %w", err)
+	}
+
+	if resp.Header.Get("X-KubeCIA-Stale") == "true" {
+		tk.MarkStale()
+	}
+
+	return &tk, nil
+}
+
+func (cli *Client) getToken(ctx context.Context) (*token.Token, error) {
+	c, err := cache.Open(ctx, cli.Cache)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cache: %w", err)
+	}
+
+	defer func() { _ = c.Close() }()
+
+	o := TokenOptions{
+		APIToken: cli.APIToken,
+		Cluster:  cli.Cluster,
+		BaseURL:  cli.BaseURL,
+	}
+
+	return cli.Provider.GetToken(ctx, o, c)
+}
+
+func wrapRemoteCallError(err error) error {
+	return remoteCallError{err: err}
+}
+
+type remoteCallError struct {
+	err error
+}
+
+func (e remoteCallError) Error() string {
+	return e.err.Error()
+}
+
+// NewClient returns the Client of the Provider.
+func (p *Provider) NewClient() *Client {
+	return &Client{Provider: p}
+}
@@ -0,0 +1,41 @@
+package apitoken
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_GetToken_baseURL(t *testing.T) {
+	var served bool
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = true
+
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}))
+	t.Cleanup(srv.Close)
+
+	p := &Provider{
+		Namespace:      "fake",
+		DefaultBaseURL: "https://api.example.com",
+	}
+
+	cli := p.NewClient()
+	cli.Socket = srv.URL
+	cli.APIToken = "token"
+	cli.Cluster = "c1"
+	cli.BaseURL = "https://api.internal.example.com"
+
+	// Refuse the custom endpoint instead of replacing it with the one of the central service.
+	_, err := cli.GetToken(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "--base-url") {
+		t.Errorf("expected error of the unsupported base URL, got %v", err)
+	}
+
+	if served {
+		t.Error("expected no request to the central service")
+	}
+}
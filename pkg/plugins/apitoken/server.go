@@ -0,0 +1,135 @@
+package apitoken
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/apis"
+	"github.com/seal-io/kubecia/pkg/apis/server"
+	"github.com/seal-io/kubecia/pkg/token"
+)
+
+func (p *Provider) Serve(ctx context.Context, mux *http.ServeMux, opts server.ServeOptions) error {
+	klog.Infof("serving %[1]s: /%[1]s/{cluster}\n", p.Namespace)
+
+	rp := apis.RoutePrefix(p.Namespace)
+	hd := http.StripPrefix(rp, &apiServer{
+		ServeOptions: opts,
+		Logger:       klog.LoggerWithName(klog.Background(), p.Namespace),
+		Provider:     p,
+	})
+
+	mux.Handle(rp, hd)
+
+	return nil
+}
+
+type apiServer struct {
+	server.ServeOptions
+
+	Logger   klog.Logger
+	Provider *Provider
+}
+
+func (s *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c := http.StatusMethodNotAllowed
+		http.Error(w, http.StatusText(c), c)
+
+		return
+	}
+
+	var o TokenOptions
+
+	// Authorization: Bearer {apiToken}.
+	{
+		var found bool
+
+		o.APIToken, found = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found {
+			c := http.StatusUnauthorized
+			http.Error(w, http.StatusText(c), c)
+
+			return
+		}
+	}
+
+	// Path: {cluster}.
+	{
+		o.Cluster = strings.Trim(r.URL.Path, "/")
+		if o.Cluster == "" || strings.Contains(o.Cluster, "/") {
+			c := http.StatusBadRequest
+			http.Error(w, http.StatusText(c), c)
+
+			return
+		}
+	}
+
+	if !s.Authorizer.Authorize(w, r, server.Attributes{
+		Provider: s.Provider.Namespace,
+		Cluster:  o.Cluster,
+	}) {
+		return
+	}
+
+	if s.RefuseEnv(w, o.APIToken) {
+		return
+	}
+
+	// Validate ahead, so that the tracked and logged key is the one of the cached token.
+	if err := s.Provider.Validate(&o); err != nil {
+		http.Error(w, "invalid options: "+err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	key, err := s.Provider.Key(o)
+	if err != nil {
+		s.Logger.Error(err, "error keying token")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	tk, err := s.Provider.GetToken(r.Context(), o, s.Cache)
+	if err != nil {
+		server.ErrorToken(w, s.Logger, err)
+
+		return
+	}
+
+	s.Refresher.Track(key, tk, func(ctx context.Context) (*token.Token, error) {
+		return s.Provider.RefreshToken(ctx, o, s.Cache)
+	})
+
+	var bs []byte
+	if r.Header.Get("X-KubeCIA-DeCapsuled") == "true" {
+		bs, err = tk.MarshalJSON()
+	} else {
+		bs, err = tk.ToKubeClientExecCredentialJSON()
+	}
+
+	if err != nil {
+		s.Logger.Error(err, "error marshaling token")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if tk.Stale() {
+		w.Header().Set("X-KubeCIA-Stale", "true")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	_, err = w.Write(bs)
+	if err != nil {
+		s.Logger.Error(err, "error writing response")
+		return
+	}
+
+	server.LogIssued(s.Logger, r, key, tk)
+}
@@ -0,0 +1,220 @@
+// Package apitoken implements the providers,
+// which exchange a personal API token for the cluster credential,
+// e.g. DigitalOcean and Linode.
+package apitoken
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/bytespool"
+	"github.com/seal-io/kubecia/pkg/cache"
+	"github.com/seal-io/kubecia/pkg/token"
+	"github.com/seal-io/kubecia/pkg/version"
+)
+
+const (
+	// FallbackExpiration is the lifetime of the credential without expiration,
+	// e.g. the LKE kubeconfig lives until it is reset,
+	// so it is refreshed after this period to pick up rotations,
+	// and never outlives the client certificate.
+	FallbackExpiration = 1 * time.Hour
+
+	requestTimeout = 30 * time.Second
+)
+
+// Provider describes the provider exchanging the API token for the cluster credential.
+type Provider struct {
+	// Namespace is the route namespace, e.g. "digitalocean".
+	Namespace string
+	// DisplayName is the name shown in the help text, e.g. "DigitalOcean".
+	DisplayName string
+	// ClusterDescription describes the cluster flag, e.g. "DigitalOcean Kubernetes cluster ID".
+	ClusterDescription string
+	// DefaultBaseURL is the default endpoint of the API.
+	DefaultBaseURL string
+	// Fetch requests the cluster credential with the validated options,
+	// the Expiration of the returned token can be zero if the API does not tell.
+	Fetch func(ctx context.Context, opts TokenOptions) (*token.Token, error)
+}
+
+type TokenOptions struct {
+	APIToken string
+	Cluster  string
+	// BaseURL is the endpoint of the API,
+	// default is Provider.DefaultBaseURL.
+	BaseURL string
+}
+
+// Validate validates the given options,
+// the API token of the form "$VAR" is expanded from the environment.
+func (p *Provider) Validate(o *TokenOptions) error {
+	var requiredTenant bool
+
+	if strings.HasPrefix(o.APIToken, "$") {
+		o.APIToken = os.ExpandEnv(o.APIToken)
+		requiredTenant = true
+	}
+
+	if o.APIToken == "" {
+		if requiredTenant {
+			return errors.New("hosted API token is required")
+		}
+
+		return errors.New("API token is required")
+	}
+
+	if o.Cluster == "" {
+		return errors.New("cluster ID is required")
+	}
+
+	if o.BaseURL == "" {
+		o.BaseURL = p.DefaultBaseURL
+	}
+
+	if u, err := url.Parse(o.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New("base URL must be an absolute URL")
+	}
+
+	return nil
+}
+
+func (p *Provider) Key(o TokenOptions) (string, error) {
+	d, err := cache.Digest(p.Namespace, o.APIToken, o.Cluster, o.BaseURL)
+	if err != nil {
+		return "", err
+	}
+
+	ss := []string{
+		p.Namespace,
+		o.Cluster,
+		d,
+	}
+
+	return strings.Join(ss, "_"), nil
+}
+
+// Describe returns the description of the token requested with the given options.
+func (p *Provider) Describe(o TokenOptions) token.Description {
+	return token.Description{
+		Cluster: o.Cluster,
+	}
+}
+
+// GetToken retrieves a token from cache or remote.
+func (p *Provider) GetToken(ctx context.Context, opts TokenOptions, cacher cache.Cache) (*token.Token, error) {
+	logger := klog.LoggerWithName(klog.Background(), p.Namespace)
+
+	err := p.Validate(&opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+
+	ctx = token.WithDescription(ctx, p.Describe(opts))
+
+	key, err := p.Key(opts)
+	if err != nil {
+		return nil, fmt.Errorf("error keying token: %w", err)
+	}
+
+	return token.GetCached(ctx, logger, cacher, key, p.fetchToken(opts))
+}
+
+// RefreshToken requests a token from remote and saves it into cache,
+// ignores the cached one.
+func (p *Provider) RefreshToken(ctx context.Context, opts TokenOptions, cacher cache.Cache) (*token.Token, error) {
+	logger := klog.LoggerWithName(klog.Background(), p.Namespace)
+
+	err := p.Validate(&opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+
+	ctx = token.WithDescription(ctx, p.Describe(opts))
+
+	key, err := p.Key(opts)
+	if err != nil {
+		return nil, fmt.Errorf("error keying token: %w", err)
+	}
+
+	return token.Refresh(ctx, logger, cacher, key, p.fetchToken(opts))
+}
+
+func (p *Provider) fetchToken(opts TokenOptions) token.FetchFunc {
+	return func(ctx context.Context) (*token.Token, error) {
+		ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+
+		tk, err := p.Fetch(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error getting cluster credential: %w", err)
+		}
+
+		if tk.Value == "" && !tk.HasClientCertificate() {
+			return nil, errors.New("error getting cluster credential: no credential found")
+		}
+
+		if tk.Expiration.IsZero() {
+			tk.Expiration = time.Now().Add(FallbackExpiration)
+		}
+
+		// Do not outlive the client certificate.
+		if ce := tk.ClientCertificateExpiration(); !ce.IsZero() && ce.Before(tk.Expiration) {
+			tk.Expiration = ce
+		}
+
+		return tk, nil
+	}
+}
+
+// Get requests the given path and query of the API with the API token,
+// and returns the body of the successful response.
+func Get(ctx context.Context, opts TokenOptions, query url.Values, path ...string) ([]byte, error) {
+	u, err := url.JoinPath(opts.BaseURL, path...)
+	if err != nil {
+		return nil, fmt.Errorf("error building request url: %w", err)
+	}
+
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+opts.APIToken)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", version.Get())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	buf := bytespool.GetBuffer()
+	defer bytespool.Put(buf)
+
+	_, err = io.Copy(buf, resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error copying response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error response: %s: %s", resp.Status, strings.TrimSpace(buf.String()))
+	}
+
+	return append([]byte(nil), buf.Bytes()...), nil
+}
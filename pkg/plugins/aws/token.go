@@ -72,19 +72,38 @@ func (o *TokenOptions) Validate() error {
 	return nil
 }
 
-func (o *TokenOptions) Key() string {
+func (o *TokenOptions) Key() (string, error) {
+	d, err := cache.Digest(Namespace, o.AccessKeyID, o.SecretAccessKey, o.Region, o.Cluster, o.AssumeRoleARN)
+	if err != nil {
+		return "", err
+	}
+
 	ss := []string{
 		Namespace,
 		o.AccessKeyID,
 		o.Region,
 		o.Cluster,
 		o.AssumeRoleARN,
+		d,
 	}
 	if o.AssumeRoleARN == "" {
-		ss[len(ss)-1] = "self"
+		ss[len(ss)-2] = "self"
 	}
 
-	return strings.Join(ss, "_")
+	return strings.Join(ss, "_"), nil
+}
+
+// Describe returns the description of the token requested with the options.
+func (o *TokenOptions) Describe() token.Description {
+	id := o.AccessKeyID
+	if o.AssumeRoleARN != "" {
+		id += "/" + o.AssumeRoleARN
+	}
+
+	return token.Description{
+		Identity: id,
+		Cluster:  o.Region + "/" + o.Cluster,
+	}
 }
 
 // GetToken retrieves a token from cache or remote.
@@ -96,50 +115,45 @@ func GetToken(ctx context.Context, opts TokenOptions, cacher cache.Cache) (*toke
 		return nil, fmt.Errorf("invalid options: %w", err)
 	}
 
-	// Retrieve the token from cache.
-	ck := opts.Key()
-	if cacher != nil {
-		bs, err := cacher.Get(ctx, ck)
-		if err != nil && !errors.Is(err, cache.ErrEntryNotFound) {
-			logger.Error(err, "error retrieving token from cache")
-		}
+	ctx = token.WithDescription(ctx, opts.Describe())
 
-		if len(bs) != 0 {
-			var tk token.Token
-			if err = tk.UnmarshalBinary(bs); err == nil {
-				if !tk.Expired() {
-					return &tk, nil
-				}
-			}
-
-			if err != nil {
-				logger.Error(err, "error unmarshalling cached token")
-			}
-		}
+	key, err := opts.Key()
+	if err != nil {
+		return nil, fmt.Errorf("error keying token: %w", err)
+	}
+
+	return token.GetCached(ctx, logger, cacher, key, fetchToken(opts))
+}
+
+// RefreshToken requests a token from remote and saves it into cache,
+// ignores the cached one.
+func RefreshToken(ctx context.Context, opts TokenOptions, cacher cache.Cache) (*token.Token, error) {
+	logger := klog.LoggerWithName(klog.Background(), Namespace)
+
+	err := opts.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
 	}
 
-	// Request the token from remote.
-	tk, err := getToken(ctx, opts)
+	ctx = token.WithDescription(ctx, opts.Describe())
+
+	key, err := opts.Key()
 	if err != nil {
-		return nil, fmt.Errorf("error getting security token: %w", err)
+		return nil, fmt.Errorf("error keying token: %w", err)
 	}
 
-	// Save the token into cache.
-	if cacher != nil {
-		bs, err := tk.MarshalBinary()
+	return token.Refresh(ctx, logger, cacher, key, fetchToken(opts))
+}
+
+func fetchToken(opts TokenOptions) token.FetchFunc {
+	return func(ctx context.Context) (*token.Token, error) {
+		tk, err := getToken(ctx, opts)
 		if err != nil {
-			logger.Error(err, "error marshaling requested token")
+			return nil, fmt.Errorf("error getting security token: %w", err)
 		}
 
-		if len(bs) != 0 {
-			err = cacher.Set(ctx, ck, bs)
-			if err != nil {
-				logger.Error(err, "error saving token to cache")
-			}
-		}
+		return tk, nil
 	}
-
-	return tk, nil
 }
 
 const (
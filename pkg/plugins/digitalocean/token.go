@@ -0,0 +1,92 @@
+package digitalocean
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/seal-io/kubecia/pkg/apis/server"
+	"github.com/seal-io/kubecia/pkg/json"
+	"github.com/seal-io/kubecia/pkg/plugins/apitoken"
+	"github.com/seal-io/kubecia/pkg/token"
+)
+
+const (
+	Namespace = "digitalocean"
+
+	// DefaultBaseURL is the default endpoint of DigitalOcean API.
+	DefaultBaseURL = "https://api.digitalocean.com"
+
+	// credentialExpiration is the lifetime requested for the cluster credential,
+	// the API defaults to 7 days which is too long for an exec credential.
+	credentialExpiration = 1 * time.Hour
+)
+
+// Provider exchanges the DigitalOcean personal access token for the DOKS cluster credential.
+var Provider = &apitoken.Provider{
+	Namespace:          Namespace,
+	DisplayName:        "DigitalOcean",
+	ClusterDescription: "DigitalOcean Kubernetes cluster ID",
+	DefaultBaseURL:     DefaultBaseURL,
+	Fetch:              getToken,
+}
+
+func Serve(ctx context.Context, mux *http.ServeMux, opts server.ServeOptions) error {
+	return Provider.Serve(ctx, mux, opts)
+}
+
+// credential is the response of
+// https://docs.digitalocean.com/reference/api/api-reference/#operation/kubernetes_get_credentials.
+type credential struct {
+	Server                   string    `json:"server"`
+	CertificateAuthorityData string    `json:"certificate_authority_data"`
+	ClientCertificateData    string    `json:"client_certificate_data"`
+	ClientKeyData            string    `json:"client_key_data"`
+	Token                    string    `json:"token"`
+	ExpiresAt                time.Time `json:"expires_at"`
+}
+
+// getToken returns the token by requesting the cluster credential,
+// the expiration falls back to apitoken.FallbackExpiration if the response misses expires_at.
+func getToken(ctx context.Context, opts apitoken.TokenOptions) (*token.Token, error) {
+	q := url.Values{
+		"expiry_seconds": []string{strconv.FormatInt(int64(credentialExpiration/time.Second), 10)},
+	}
+
+	bs, err := apitoken.Get(ctx, opts, q, "v2", "kubernetes", "clusters", url.PathEscape(opts.Cluster), "credentials")
+	if err != nil {
+		return nil, err
+	}
+
+	var cred credential
+	if err = json.Unmarshal(bs, &cred); err != nil {
+		return nil, fmt.Errorf("error unmarshalling credential: %w", err)
+	}
+
+	tk := &token.Token{
+		Expiration: cred.ExpiresAt,
+		Value:      cred.Token,
+	}
+
+	// Client certificate and key are base64 encoded PEM blocks.
+	if cred.ClientCertificateData != "" && cred.ClientKeyData != "" {
+		crt, err := base64.StdEncoding.DecodeString(cred.ClientCertificateData)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding client certificate: %w", err)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(cred.ClientKeyData)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding client key: %w", err)
+		}
+
+		tk.ClientCertificateData = string(crt)
+		tk.ClientKeyData = string(key)
+	}
+
+	return tk, nil
+}
@@ -0,0 +1,169 @@
+package digitalocean
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seal-io/kubecia/pkg/cache"
+	"github.com/seal-io/kubecia/pkg/plugins/apitoken"
+)
+
+// newCertificate returns a self-signed client certificate and key in PEM,
+// which expires at the given time.
+func newCertificate(t *testing.T, notAfter time.Time) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+
+	kder, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("error marshaling key: %v", err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}))
+}
+
+func TestGetToken(t *testing.T) {
+	var (
+		expiresAt = time.Now().Add(30 * time.Minute).UTC().Truncate(time.Second)
+		crt, key  = newCertificate(t, time.Now().Add(20*time.Minute).UTC().Truncate(time.Second))
+	)
+
+	cases := []struct {
+		name        string
+		status      int
+		body        string
+		expected    string
+		certificate bool
+		expiration  time.Duration
+		invalid     bool
+	}{
+		{
+			name:       "token",
+			status:     http.StatusOK,
+			body:       `{"token": "t", "expires_at": "` + expiresAt.Format(time.RFC3339) + `"}`,
+			expected:   "t",
+			expiration: 30 * time.Minute,
+		},
+		{
+			name:       "token without expiration",
+			status:     http.StatusOK,
+			body:       `{"token": "t"}`,
+			expected:   "t",
+			expiration: apitoken.FallbackExpiration,
+		},
+		{
+			name:   "client certificate",
+			status: http.StatusOK,
+			body: `{"client_certificate_data": "` + base64.StdEncoding.EncodeToString([]byte(crt)) +
+				`", "client_key_data": "` + base64.StdEncoding.EncodeToString([]byte(key)) + `"}`,
+			certificate: true,
+			// Never outlive the client certificate.
+			expiration: 20 * time.Minute,
+		},
+		{
+			name:    "invalid client certificate",
+			status:  http.StatusOK,
+			body:    `{"client_certificate_data": "!", "client_key_data": "!"}`,
+			invalid: true,
+		},
+		{
+			name:    "no credential",
+			status:  http.StatusOK,
+			body:    `{}`,
+			invalid: true,
+		},
+		{
+			name:    "unauthorized",
+			status:  http.StatusUnauthorized,
+			body:    `{"id": "unauthorized", "message": "Unable to authenticate you"}`,
+			invalid: true,
+		},
+		{
+			name:    "not found",
+			status:  http.StatusNotFound,
+			body:    `{"id": "not_found", "message": "The resource you requested could not be found."}`,
+			invalid: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v2/kubernetes/clusters/c1/credentials" ||
+					r.URL.Query().Get("expiry_seconds") != "3600" ||
+					r.Header.Get("Authorization") != "Bearer do-token" {
+					http.Error(w, "unexpected request", http.StatusBadRequest)
+					return
+				}
+
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			t.Cleanup(srv.Close)
+
+			o := apitoken.TokenOptions{
+				APIToken: "do-token",
+				Cluster:  "c1",
+				BaseURL:  srv.URL,
+			}
+
+			tk, err := Provider.GetToken(context.Background(), o, cache.NewNone())
+			if tc.invalid {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+
+				if tc.status != http.StatusOK && !strings.Contains(err.Error(), http.StatusText(tc.status)) {
+					t.Errorf("expected the error status %d, got %v", tc.status, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tk.Value != tc.expected {
+				t.Errorf("expected token %q, got %q", tc.expected, tk.Value)
+			}
+
+			if tc.certificate && (tk.ClientCertificateData != crt || tk.ClientKeyData != key) {
+				t.Error("expected the decoded client certificate and key")
+			}
+
+			if d := time.Until(tk.Expiration); d > tc.expiration || d < tc.expiration-time.Minute {
+				t.Errorf("expected expiring in %s, got %s", tc.expiration, d)
+			}
+		})
+	}
+}
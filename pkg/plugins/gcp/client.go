@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 
 	"github.com/spf13/pflag"
 	"k8s.io/klog/v2"
@@ -21,6 +20,7 @@ import (
 
 type Client struct {
 	Socket       string
+	Cache        string
 	ClientID     string
 	ClientSecret string
 	Region       string
@@ -28,7 +28,10 @@ type Client struct {
 }
 
 func (cli *Client) AddFlags(flags *pflag.FlagSet) {
-	flags.StringVar(&cli.Socket, "socket", consts.SocketPath(), "Socket path")
+	flags.StringVar(&cli.Socket, "socket", consts.SocketPath(),
+		"Socket path or URL of the central service, e.g. /var/run/kubecia.sock or https://kubecia.example.com:8443")
+	flags.StringVar(&cli.Cache, "cache", "file://",
+		"Cache DSN, e.g. file:///path?buckets=12, memory:// or none://")
 	flags.StringVar(&cli.ClientID, "client-id", "", "GCP client ID *")
 	flags.StringVar(&cli.ClientSecret, "client-secret", "", "GCP client secret *")
 	flags.StringVar(&cli.Region, "region", "", "GCP region *")
@@ -38,7 +41,7 @@ func (cli *Client) AddFlags(flags *pflag.FlagSet) {
 func (cli *Client) GetToken(ctx context.Context) (*token.Token, error) {
 	logger := klog.LoggerWithName(klog.Background(), Namespace)
 
-	if si, err := os.Stat(cli.Socket); err == nil && si.Mode()&os.ModeSocket != 0 {
+	if apis.Served(cli.Socket) {
 		logger.V(6).Info("getting from central service")
 
 		tk, err := cli.GetTokenByHTTP(ctx, apis.Client(cli.Socket))
@@ -89,7 +92,7 @@ func (cli *Client) GetTokenByHTTP(ctx context.Context, httpc *http.Client) (*tok
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error response from remote: %s", resp.Status)
+		return nil, apis.ResponseError(resp)
 	}
 
 	buf := bytespool.GetBuffer()
@@ -105,11 +108,15 @@ func (cli *Client) GetTokenByHTTP(ctx context.Context, httpc *http.Client) (*tok
 		return nil, fmt.Errorf("error unmarshalling requested token: %w", err)
 	}
 
+	if resp.Header.Get("X-KubeCIA-Stale") == "true" {
+		tk.MarkStale()
+	}
+
 	return &tk, nil
 }
 
 func (cli *Client) getToken(ctx context.Context) (*token.Token, error) {
-	c, err := cache.NewFile(ctx)
+	c, err := cache.Open(ctx, cli.Cache)
 	if err != nil {
 		return nil, fmt.Errorf("error creating cache: %w", err)
 	}
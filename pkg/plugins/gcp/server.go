@@ -9,6 +9,7 @@ import (
 
 	"github.com/seal-io/kubecia/pkg/apis"
 	"github.com/seal-io/kubecia/pkg/apis/server"
+	"github.com/seal-io/kubecia/pkg/token"
 )
 
 const (
@@ -72,14 +73,46 @@ func (s *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		o.Cluster = paths[1]
 	}
 
-	tk, err := GetToken(r.Context(), o, s.Cache)
+	if !s.Authorizer.Authorize(w, r, server.Attributes{
+		Provider: Namespace,
+		Cluster:  o.Region + "/" + o.Cluster,
+	}) {
+		return
+	}
+
+	if s.RefuseEnv(w, o.ClientID, o.ClientSecret) {
+		return
+	}
+
+	// Validate ahead, so that the tracked and logged key is the one of the cached token.
+	if err := o.Validate(); err != nil {
+		http.Error(w, "invalid options: "+err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	key, err := o.Key()
 	if err != nil {
-		s.Logger.Error(err, "error getting token")
+		s.Logger.Error(err, "error keying token")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 
 		return
 	}
 
+	// Verify the presented secret before returning the cached token.
+	ctx := token.WithVerifier(r.Context(), o.ClientSecret)
+
+	tk, err := GetToken(ctx, o, s.Cache)
+	if err != nil {
+		server.ErrorToken(w, s.Logger, err)
+
+		return
+	}
+
+	s.Refresher.Track(key, tk, func(ctx context.Context) (*token.Token, error) {
+		return RefreshToken(token.WithVerifier(ctx, o.ClientSecret), o, s.Cache)
+	})
+
 	var bs []byte
 	if r.Header.Get("X-KubeCIA-DeCapsuled") == "true" {
 		bs, err = tk.MarshalJSON()
@@ -94,6 +127,10 @@ func (s *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if tk.Stale() {
+		w.Header().Set("X-KubeCIA-Stale", "true")
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
 	_, err = w.Write(bs)
@@ -101,4 +138,6 @@ func (s *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		s.Logger.Error(err, "error writing response")
 		return
 	}
+
+	server.LogIssued(s.Logger, r, key, tk)
 }
@@ -0,0 +1,169 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/apis"
+	"github.com/seal-io/kubecia/pkg/bytespool"
+	"github.com/seal-io/kubecia/pkg/cache"
+	"github.com/seal-io/kubecia/pkg/consts"
+	"github.com/seal-io/kubecia/pkg/token"
+	"github.com/seal-io/kubecia/pkg/version"
+)
+
+type Client struct {
+	Socket            string
+	Cache             string
+	Kubeconfig        string
+	Context           string
+	Namespace         string
+	ServiceAccount    string
+	Audiences         []string
+	ExpirationSeconds int64
+}
+
+func (cli *Client) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&cli.Socket, "socket", consts.SocketPath(),
+		"Socket path or URL of the central service, e.g. /var/run/kubecia.sock or https://kubecia.example.com:8443")
+	flags.StringVar(&cli.Cache, "cache", "file://",
+		"Cache DSN, e.g. file:///path?buckets=12, memory:// or none://")
+	flags.StringVar(&cli.Kubeconfig, "kubeconfig", "",
+		"Source kubeconfig path, in-cluster config or $KUBECONFIG is used if blank")
+	flags.StringVar(&cli.Context, "context", "", "Source kubeconfig context")
+	flags.StringVar(&cli.Namespace, "namespace", "", "Kubernetes namespace of the service account *")
+	flags.StringVar(&cli.ServiceAccount, "service-account", "", "Kubernetes service account name *")
+	flags.StringSliceVar(&cli.Audiences, "audience", nil, "Kubernetes token audiences")
+	flags.Int64Var(&cli.ExpirationSeconds, "expiration-seconds", DefaultExpirationSeconds,
+		"Kubernetes token expiration seconds")
+}
+
+func (cli *Client) GetToken(ctx context.Context) (*token.Token, error) {
+	logger := klog.LoggerWithName(klog.Background(), Namespace)
+
+	// The central service requests with its own source,
+	// so only delegate if no source specified.
+	if apis.Served(cli.Socket) &&
+		cli.Kubeconfig == "" && cli.Context == "" {
+		logger.V(6).Info("getting from central service")
+
+		tk, err := cli.GetTokenByHTTP(ctx, apis.Client(cli.Socket))
+		if err == nil {
+			logger.V(6).Info("got from central service")
+
+			return tk, nil
+		}
+
+		var rce remoteCallError
+		if !errors.As(err, &rce) {
+			return nil, err
+		}
+
+		logger.Error(err, "error getting from central service, try getting locally")
+	} else {
+		logger.V(6).Info("getting locally")
+	}
+
+	tk, err := cli.getToken(ctx)
+	if err == nil {
+		logger.V(6).Info("got locally")
+
+		return tk, nil
+	}
+
+	return nil, fmt.Errorf("error getting token locally: %w", err)
+}
+
+func (cli *Client) GetTokenByHTTP(ctx context.Context, httpc *http.Client) (*token.Token, error) {
+	q := url.Values{}
+	for i := range cli.Audiences {
+		q.Add("audience", cli.Audiences[i])
+	}
+
+	if cli.ExpirationSeconds != 0 {
+		q.Set("expirationSeconds", strconv.FormatInt(cli.ExpirationSeconds, 10))
+	}
+
+	url := apis.Route(Namespace, cli.Namespace, cli.ServiceAccount)
+	if len(q) != 0 {
+		url += "?" + q.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, wrapRemoteCallError(fmt.Errorf("error creating remote request: %w", err))
+	}
+
+	req.Header.Set("User-Agent", version.Get())
+	req.Header.Set("X-KubeCIA-DeCapsuled", "true")
+
+	resp, err := httpc.Do(req)
+	if err != nil {
+		return nil, wrapRemoteCallError(fmt.Errorf("error making remote request: %w", err))
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apis.ResponseError(resp)
+	}
+
+	buf := bytespool.GetBuffer()
+	defer bytespool.Put(buf)
+
+	_, err = io.Copy(buf, resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error copying response body: %w", err)
+	}
+
+	var tk token.Token
+	if err = tk.UnmarshalJSON(buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("error unmarshalling requested token: %w", err)
+	}
+
+	if resp.Header.Get("X-KubeCIA-Stale") == "true" {
+		tk.MarkStale()
+	}
+
+	return &tk, nil
+}
+
+func (cli *Client) getToken(ctx context.Context) (*token.Token, error) {
+	c, err := cache.Open(ctx, cli.Cache)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cache: %w", err)
+	}
+
+	defer func() { _ = c.Close() }()
+
+	o := TokenOptions{
+		Kubeconfig:        cli.Kubeconfig,
+		Context:           cli.Context,
+		Namespace:         cli.Namespace,
+		ServiceAccount:    cli.ServiceAccount,
+		Audiences:         cli.Audiences,
+		ExpirationSeconds: cli.ExpirationSeconds,
+	}
+
+	return GetToken(ctx, o, c)
+}
+
+func wrapRemoteCallError(err error) error {
+	return remoteCallError{err: err}
+}
+
+type remoteCallError struct {
+	err error
+}
+
+func (e remoteCallError) Error() string {
+	return e.err.Error()
+}
@@ -0,0 +1,192 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/apis"
+	"github.com/seal-io/kubecia/pkg/apis/server"
+	"github.com/seal-io/kubecia/pkg/token"
+)
+
+const (
+	Namespace = "kubernetes"
+)
+
+// Server serves the service account tokens,
+// which are requested with the identity of the central service,
+// so only the allowed service accounts are served.
+type Server struct {
+	// ServiceAccounts are the allowed service accounts in the form of "{namespace}/{name}",
+	// which accept the "*" wildcard, the provider is disabled if empty.
+	ServiceAccounts []string
+}
+
+func (s *Server) AddFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVar(&s.ServiceAccounts, "kubernetes-service-accounts", nil,
+		"Service accounts allowed to request, in the form of {namespace}/{name} with * wildcard, repeatable, "+
+			"enables the kubernetes provider if specified")
+}
+
+func (s *Server) Serve(ctx context.Context, mux *http.ServeMux, opts server.ServeOptions) error {
+	if len(s.ServiceAccounts) == 0 {
+		return nil
+	}
+
+	for _, sa := range s.ServiceAccounts {
+		if ns, n, ok := strings.Cut(sa, "/"); !ok || ns == "" || n == "" {
+			return fmt.Errorf("invalid kubernetes service account %q: must be {namespace}/{name}", sa)
+		}
+	}
+
+	// Build once, and share among the requests.
+	cli, err := newClientset(TokenOptions{})
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+
+	klog.Infof("serving %[1]s: /%[1]s/{namespace}/{service-account}[?audience=...&expirationSeconds=...]\n",
+		Namespace)
+
+	rp := apis.RoutePrefix(Namespace)
+	hd := http.StripPrefix(rp, &apiServer{
+		ServeOptions: opts,
+		Logger:       klog.LoggerWithName(klog.Background(), Namespace),
+		Clientset:    cli,
+		Allowed:      server.NewWildcards(s.ServiceAccounts),
+	})
+
+	mux.Handle(rp, hd)
+
+	return nil
+}
+
+type apiServer struct {
+	server.ServeOptions
+
+	Logger    klog.Logger
+	Clientset k8s.Interface
+	Allowed   server.Wildcards
+}
+
+func (s *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c := http.StatusMethodNotAllowed
+		http.Error(w, http.StatusText(c), c)
+
+		return
+	}
+
+	var o TokenOptions
+
+	// Path: {namespace}/{service-account}.
+	{
+		paths := strings.SplitN(r.URL.Path, "/", 2)
+		if len(paths) < 2 {
+			c := http.StatusBadRequest
+			http.Error(w, http.StatusText(c), c)
+
+			return
+		}
+
+		o.Namespace = paths[0]
+		o.ServiceAccount = paths[1]
+	}
+
+	// Query: [audience=...&]expirationSeconds=....
+	{
+		q := r.URL.Query()
+
+		o.Audiences = q["audience"]
+
+		if v := q.Get("expirationSeconds"); v != "" {
+			var err error
+
+			o.ExpirationSeconds, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				c := http.StatusBadRequest
+				http.Error(w, http.StatusText(c), c)
+
+				return
+			}
+		}
+	}
+
+	if !s.Allowed.Match(o.Namespace + "/" + o.ServiceAccount) {
+		s.Logger.Info("denied", "namespace", o.Namespace, "serviceAccount", o.ServiceAccount)
+
+		c := http.StatusForbidden
+		http.Error(w, http.StatusText(c), c)
+
+		return
+	}
+
+	if !s.Authorizer.Authorize(w, r, server.Attributes{
+		Provider: Namespace,
+		Tenant:   o.Namespace,
+		Role:     o.ServiceAccount,
+	}) {
+		return
+	}
+
+	// Validate ahead, so that the tracked and logged key is the one of the cached token.
+	if err := o.Validate(); err != nil {
+		http.Error(w, "invalid options: "+err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	key, err := o.Key()
+	if err != nil {
+		s.Logger.Error(err, "error keying token")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	tk, err := GetTokenWithClientset(r.Context(), o, s.Clientset, s.Cache)
+	if err != nil {
+		server.ErrorToken(w, s.Logger, err)
+
+		return
+	}
+
+	s.Refresher.Track(key, tk, func(ctx context.Context) (*token.Token, error) {
+		return RefreshTokenWithClientset(ctx, o, s.Clientset, s.Cache)
+	})
+
+	var bs []byte
+	if r.Header.Get("X-KubeCIA-DeCapsuled") == "true" {
+		bs, err = tk.MarshalJSON()
+	} else {
+		bs, err = tk.ToKubeClientExecCredentialJSON()
+	}
+
+	if err != nil {
+		s.Logger.Error(err, "error marshaling token")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if tk.Stale() {
+		w.Header().Set("X-KubeCIA-Stale", "true")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	_, err = w.Write(bs)
+	if err != nil {
+		s.Logger.Error(err, "error writing response")
+		return
+	}
+
+	server.LogIssued(s.Logger, r, key, tk)
+}
@@ -0,0 +1,52 @@
+package kubernetes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/apis/server"
+)
+
+func TestServeHTTP_allowed(t *testing.T) {
+	cli, n := newFakeClientset()
+
+	s := &apiServer{
+		ServeOptions: server.ServeOptions{Cache: newMemoryCache(t)},
+		Logger:       klog.Background(),
+		Clientset:    cli,
+		Allowed:      server.NewWildcards([]string{"ci/*", "dev/builder"}),
+	}
+
+	cases := []struct {
+		path     string
+		expected int
+	}{
+		{path: "ci/deployer", expected: http.StatusOK},
+		{path: "dev/builder", expected: http.StatusOK},
+		{path: "dev/admin", expected: http.StatusForbidden},
+		{path: "kube-system/default", expected: http.StatusForbidden},
+		{path: "ci", expected: http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.path, func(t *testing.T) {
+			// The handler is mounted behind http.StripPrefix.
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.URL.Path = tc.path
+
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+
+			if w.Code != tc.expected {
+				t.Errorf("expected %d, got %d: %s", tc.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if n.Load() != 2 {
+		t.Errorf("expected 2 requests of the allowed service accounts, got %d", n.Load())
+	}
+}
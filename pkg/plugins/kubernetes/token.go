@@ -0,0 +1,257 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	authnv1 "k8s.io/api/authentication/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+
+	"github.com/seal-io/kubecia/pkg/cache"
+	"github.com/seal-io/kubecia/pkg/token"
+)
+
+// DefaultExpirationSeconds is the default lifetime of the requested token,
+// the API server may return a token with a different lifetime.
+const DefaultExpirationSeconds = 3600
+
+type TokenOptions struct {
+	// Kubeconfig is the path of the source kubeconfig,
+	// uses in-cluster config or the default loading rules if blank.
+	Kubeconfig string
+	// Context is the context to use in Kubeconfig,
+	// uses the current context if blank.
+	Context string
+
+	Namespace         string
+	ServiceAccount    string
+	Audiences         []string
+	ExpirationSeconds int64
+}
+
+func (o *TokenOptions) Validate() error {
+	if o.Namespace == "" {
+		return errors.New("namespace is required")
+	}
+
+	if errs := validation.IsDNS1123Label(o.Namespace); len(errs) != 0 {
+		return fmt.Errorf("invalid namespace: %s", strings.Join(errs, ", "))
+	}
+
+	if o.ServiceAccount == "" {
+		return errors.New("service account is required")
+	}
+
+	if errs := validation.IsDNS1123Subdomain(o.ServiceAccount); len(errs) != 0 {
+		return fmt.Errorf("invalid service account: %s", strings.Join(errs, ", "))
+	}
+
+	if o.ExpirationSeconds == 0 {
+		o.ExpirationSeconds = DefaultExpirationSeconds
+	}
+
+	// The API server rejects the request with expiration less than 10 mins.
+	if o.ExpirationSeconds < 600 {
+		return errors.New("expiration seconds must be at least 600")
+	}
+
+	return nil
+}
+
+func (o *TokenOptions) Key() (string, error) {
+	auds := make([]string, len(o.Audiences))
+	copy(auds, o.Audiences)
+	sort.Strings(auds)
+
+	// Hash the source and audiences to keep the key short and path safe.
+	d, err := cache.Digest(
+		Namespace,
+		o.Kubeconfig, o.Context,
+		o.Namespace, o.ServiceAccount, strings.Join(auds, ","), strconv.FormatInt(o.ExpirationSeconds, 10),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	ss := []string{
+		Namespace,
+		o.Namespace,
+		o.ServiceAccount,
+		d,
+	}
+
+	return strings.Join(ss, "_"), nil
+}
+
+// Describe returns the description of the token requested with the options.
+func (o *TokenOptions) Describe() token.Description {
+	return token.Description{
+		Identity: o.Namespace + "/" + o.ServiceAccount,
+	}
+}
+
+// GetToken retrieves a token from cache or remote,
+// the clientset is only created if missing the cache.
+func GetToken(ctx context.Context, opts TokenOptions, cacher cache.Cache) (*token.Token, error) {
+	logger := klog.LoggerWithName(klog.Background(), Namespace)
+
+	err := opts.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+
+	ctx = token.WithDescription(ctx, opts.Describe())
+
+	key, err := opts.Key()
+	if err != nil {
+		return nil, fmt.Errorf("error keying token: %w", err)
+	}
+
+	return token.GetCached(ctx, logger, cacher, key, fetchToken(opts, nil))
+}
+
+// GetTokenWithClientset likes GetToken, but requests the token with the given clientset.
+func GetTokenWithClientset(
+	ctx context.Context,
+	opts TokenOptions,
+	cli k8s.Interface,
+	cacher cache.Cache,
+) (*token.Token, error) {
+	logger := klog.LoggerWithName(klog.Background(), Namespace)
+
+	err := opts.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+
+	ctx = token.WithDescription(ctx, opts.Describe())
+
+	key, err := opts.Key()
+	if err != nil {
+		return nil, fmt.Errorf("error keying token: %w", err)
+	}
+
+	return token.GetCached(ctx, logger, cacher, key, fetchToken(opts, cli))
+}
+
+// RefreshTokenWithClientset requests a token from remote with the given clientset and saves it into cache,
+// ignores the cached one.
+func RefreshTokenWithClientset(
+	ctx context.Context,
+	opts TokenOptions,
+	cli k8s.Interface,
+	cacher cache.Cache,
+) (*token.Token, error) {
+	logger := klog.LoggerWithName(klog.Background(), Namespace)
+
+	err := opts.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+
+	ctx = token.WithDescription(ctx, opts.Describe())
+
+	key, err := opts.Key()
+	if err != nil {
+		return nil, fmt.Errorf("error keying token: %w", err)
+	}
+
+	return token.Refresh(ctx, logger, cacher, key, fetchToken(opts, cli))
+}
+
+// fetchToken requests the token with the given clientset,
+// or the clientset created from the source of the given options if nil.
+func fetchToken(opts TokenOptions, cli k8s.Interface) token.FetchFunc {
+	return func(ctx context.Context) (*token.Token, error) {
+		if cli == nil {
+			var err error
+
+			cli, err = newClientset(opts)
+			if err != nil {
+				return nil, fmt.Errorf("error creating kubernetes client: %w", err)
+			}
+		}
+
+		tk, err := getToken(ctx, opts, cli)
+		if err != nil {
+			return nil, fmt.Errorf("error requesting service account token: %w", err)
+		}
+
+		return tk, nil
+	}
+}
+
+// newClientset creates a kubernetes clientset from the source of the given options,
+// tries in-cluster config first if no kubeconfig specified.
+func newClientset(opts TokenOptions) (k8s.Interface, error) {
+	var (
+		cfg *rest.Config
+		err error
+	)
+
+	if opts.Kubeconfig == "" && opts.Context == "" {
+		cfg, err = rest.InClusterConfig()
+	}
+
+	if cfg == nil {
+		rules := clientcmd.NewDefaultClientConfigLoadingRules()
+		rules.ExplicitPath = opts.Kubeconfig
+
+		cfg, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			rules,
+			&clientcmd.ConfigOverrides{CurrentContext: opts.Context},
+		).ClientConfig()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.UserAgent = rest.DefaultKubernetesUserAgent() + " kubecia"
+
+	return k8s.NewForConfig(cfg)
+}
+
+const requestTimeout = 30 * time.Second
+
+// getToken returns the token by calling the TokenRequest API of the service account.
+func getToken(ctx context.Context, opts TokenOptions, cli k8s.Interface) (*token.Token, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	tr := &authnv1.TokenRequest{
+		Spec: authnv1.TokenRequestSpec{
+			Audiences:         opts.Audiences,
+			ExpirationSeconds: ptr.To(opts.ExpirationSeconds),
+		},
+	}
+
+	tr, err := cli.CoreV1().
+		ServiceAccounts(opts.Namespace).
+		CreateToken(ctx, opts.ServiceAccount, tr, meta.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if tr.Status.Token == "" {
+		return nil, errors.New("no token found")
+	}
+
+	tk := &token.Token{
+		Expiration: tr.Status.ExpirationTimestamp.Time,
+		Value:      tr.Status.Token,
+	}
+
+	return tk, nil
+}
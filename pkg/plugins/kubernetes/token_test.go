@@ -0,0 +1,151 @@
+package kubernetes
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	authnv1 "k8s.io/api/authentication/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/seal-io/kubecia/pkg/cache"
+)
+
+// newFakeClientset returns a fake clientset serving the TokenRequest API,
+// and the counter of the requests.
+func newFakeClientset() (*fake.Clientset, *atomic.Int32) {
+	var (
+		cli = fake.NewSimpleClientset()
+		n   = &atomic.Int32{}
+	)
+
+	cli.PrependReactor("create", "serviceaccounts",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if action.GetSubresource() != "token" {
+				return false, nil, nil
+			}
+
+			tr := action.(k8stesting.CreateAction).GetObject().(*authnv1.TokenRequest)
+			tr = tr.DeepCopy()
+			tr.Status = authnv1.TokenRequestStatus{
+				Token:               "token-" + strconv.Itoa(int(n.Add(1))),
+				ExpirationTimestamp: meta.NewTime(time.Now().Add(time.Duration(*tr.Spec.ExpirationSeconds) * time.Second)),
+			}
+
+			return true, tr, nil
+		})
+
+	return cli, n
+}
+
+func newMemoryCache(t *testing.T) cache.Cache {
+	t.Helper()
+
+	c, err := cache.Open(context.Background(), "memory://")
+	if err != nil {
+		t.Fatalf("error opening cache: %v", err)
+	}
+
+	t.Cleanup(func() { _ = c.Close() })
+
+	return c
+}
+
+func TestGetTokenWithClientset(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		cli, n = newFakeClientset()
+		c      = newMemoryCache(t)
+	)
+
+	o := TokenOptions{
+		Namespace:      "ci",
+		ServiceAccount: "deployer",
+		Audiences:      []string{"https://kubernetes.default.svc"},
+	}
+
+	tk, err := GetTokenWithClientset(ctx, o, cli, c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tk.Value != "token-1" {
+		t.Errorf("expected token-1, got %q", tk.Value)
+	}
+
+	if d := time.Until(tk.Expiration); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expected default expiration about 1h, got %s", d)
+	}
+
+	// Hit the cache.
+	tk, err = GetTokenWithClientset(ctx, o, cli, c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tk.Value != "token-1" || n.Load() != 1 {
+		t.Errorf("expected cached token-1 with 1 request, got %q with %d requests", tk.Value, n.Load())
+	}
+
+	// Miss the cache with different audiences.
+	o.Audiences = []string{"vault"}
+
+	tk, err = GetTokenWithClientset(ctx, o, cli, c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tk.Value != "token-2" || n.Load() != 2 {
+		t.Errorf("expected token-2 with 2 requests, got %q with %d requests", tk.Value, n.Load())
+	}
+
+	// Refresh ignores the cache.
+	tk, err = RefreshTokenWithClientset(ctx, o, cli, c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tk.Value != "token-3" {
+		t.Errorf("expected refreshed token-3, got %q", tk.Value)
+	}
+}
+
+func TestGetTokenWithClientset_invalid(t *testing.T) {
+	cases := []struct {
+		name string
+		opts TokenOptions
+	}{
+		{
+			name: "blank namespace",
+			opts: TokenOptions{ServiceAccount: "deployer"},
+		},
+		{
+			name: "invalid service account",
+			opts: TokenOptions{Namespace: "ci", ServiceAccount: "Deployer"},
+		},
+		{
+			name: "too short expiration",
+			opts: TokenOptions{Namespace: "ci", ServiceAccount: "deployer", ExpirationSeconds: 60},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cli, n := newFakeClientset()
+
+			_, err := GetTokenWithClientset(context.Background(), tc.opts, cli, newMemoryCache(t))
+			if err == nil {
+				t.Error("expected error, got nil")
+			}
+
+			if n.Load() != 0 {
+				t.Errorf("expected no request, got %d", n.Load())
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package linode
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	clientcmdlatest "k8s.io/client-go/tools/clientcmd/api/latest"
+
+	"github.com/seal-io/kubecia/pkg/apis/server"
+	"github.com/seal-io/kubecia/pkg/json"
+	"github.com/seal-io/kubecia/pkg/plugins/apitoken"
+	"github.com/seal-io/kubecia/pkg/token"
+)
+
+const (
+	Namespace = "linode"
+
+	// DefaultBaseURL is the default endpoint of Linode API.
+	DefaultBaseURL = "https://api.linode.com"
+)
+
+// Provider exchanges the Linode personal access token for the LKE cluster credential.
+var Provider = &apitoken.Provider{
+	Namespace:          Namespace,
+	DisplayName:        "Linode",
+	ClusterDescription: "Linode Kubernetes Engine cluster ID",
+	DefaultBaseURL:     DefaultBaseURL,
+	Fetch:              getToken,
+}
+
+func Serve(ctx context.Context, mux *http.ServeMux, opts server.ServeOptions) error {
+	return Provider.Serve(ctx, mux, opts)
+}
+
+// kubeconfig is the response of
+// https://techdocs.akamai.com/linode-api/reference/get-lke-cluster-kubeconfig.
+type kubeconfig struct {
+	Kubeconfig string `json:"kubeconfig"`
+}
+
+// getToken returns the token by requesting the cluster kubeconfig,
+// the LKE kubeconfig credential lives until it is reset,
+// so the expiration is left to apitoken.FallbackExpiration.
+func getToken(ctx context.Context, opts apitoken.TokenOptions) (*token.Token, error) {
+	bs, err := apitoken.Get(ctx, opts, nil, "v4", "lke", "clusters", url.PathEscape(opts.Cluster), "kubeconfig")
+	if err != nil {
+		return nil, err
+	}
+
+	var kc kubeconfig
+	if err = json.Unmarshal(bs, &kc); err != nil {
+		return nil, fmt.Errorf("error unmarshalling kubeconfig response: %w", err)
+	}
+
+	kcBytes, err := base64.StdEncoding.DecodeString(kc.Kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding kubeconfig: %w", err)
+	}
+
+	ai, err := currentAuthInfo(kcBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token.Token{
+		Value:                 ai.Token,
+		ClientCertificateData: string(ai.ClientCertificateData),
+		ClientKeyData:         string(ai.ClientKeyData),
+	}, nil
+}
+
+// currentAuthInfo returns the user of the current context in the given kubeconfig.
+func currentAuthInfo(data []byte) (*clientcmdapi.AuthInfo, error) {
+	obj, err := runtime.Decode(clientcmdlatest.Codec, data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing kubeconfig: %w", err)
+	}
+
+	cfg, ok := obj.(*clientcmdapi.Config)
+	if !ok {
+		return nil, fmt.Errorf("unexpected kubeconfig type %T", obj)
+	}
+
+	kctx := cfg.Contexts[cfg.CurrentContext]
+	if kctx == nil {
+		// Fallback to the only context.
+		for _, c := range cfg.Contexts {
+			if kctx != nil {
+				return nil, errors.New("no current context found in kubeconfig")
+			}
+
+			kctx = c
+		}
+	}
+
+	if kctx == nil {
+		return nil, errors.New("no context found in kubeconfig")
+	}
+
+	ai := cfg.AuthInfos[kctx.AuthInfo]
+	if ai == nil {
+		return nil, fmt.Errorf("no user %q found in kubeconfig", kctx.AuthInfo)
+	}
+
+	return ai, nil
+}
@@ -0,0 +1,196 @@
+package linode
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seal-io/kubecia/pkg/cache"
+	"github.com/seal-io/kubecia/pkg/plugins/apitoken"
+)
+
+// newCertificate returns a self-signed client certificate and key in PEM,
+// which expires at the given time.
+func newCertificate(t *testing.T, notAfter time.Time) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+
+	kder, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("error marshaling key: %v", err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}))
+}
+
+// kubeconfigResponse returns the API response carrying the kubeconfig of the given current context and users.
+func kubeconfigResponse(current string, users ...string) string {
+	var sb strings.Builder
+
+	sb.WriteString("apiVersion: v1\nkind: Config\n")
+	sb.WriteString("clusters:\n- name: lke\n  cluster:\n    server: https://lke.example.com:443\n")
+	sb.WriteString("current-context: " + current + "\n")
+
+	sb.WriteString("contexts:\n")
+
+	for i := range users {
+		fmt.Fprintf(&sb, "- name: ctx-%[1]d\n  context:\n    cluster: lke\n    user: user-%[1]d\n", i)
+	}
+
+	sb.WriteString("users:\n")
+
+	for i := range users {
+		fmt.Fprintf(&sb, "- name: user-%d\n  user:\n%s", i, users[i])
+	}
+
+	return `{"kubeconfig": "` + base64.StdEncoding.EncodeToString([]byte(sb.String())) + `"}`
+}
+
+func TestGetToken(t *testing.T) {
+	crt, key := newCertificate(t, time.Now().Add(20*time.Minute))
+
+	var (
+		tokenUser = "    token: t\n"
+		otherUser = "    token: other\n"
+		certUser  = "    client-certificate-data: " + base64.StdEncoding.EncodeToString([]byte(crt)) + "\n" +
+			"    client-key-data: " + base64.StdEncoding.EncodeToString([]byte(key)) + "\n"
+	)
+
+	cases := []struct {
+		name        string
+		status      int
+		body        string
+		expected    string
+		certificate bool
+		expiration  time.Duration
+		invalid     bool
+	}{
+		{
+			name:     "token",
+			status:   http.StatusOK,
+			body:     kubeconfigResponse("ctx-1", otherUser, tokenUser),
+			expected: "t",
+			// The kubeconfig lives until it is reset.
+			expiration: apitoken.FallbackExpiration,
+		},
+		{
+			name:       "only context",
+			status:     http.StatusOK,
+			body:       kubeconfigResponse("", tokenUser),
+			expected:   "t",
+			expiration: apitoken.FallbackExpiration,
+		},
+		{
+			name:        "client certificate",
+			status:      http.StatusOK,
+			body:        kubeconfigResponse("ctx-0", certUser),
+			certificate: true,
+			// Never outlive the client certificate.
+			expiration: 20 * time.Minute,
+		},
+		{
+			name:    "ambiguous context",
+			status:  http.StatusOK,
+			body:    kubeconfigResponse("", tokenUser, otherUser),
+			invalid: true,
+		},
+		{
+			name:    "invalid kubeconfig",
+			status:  http.StatusOK,
+			body:    `{"kubeconfig": "!"}`,
+			invalid: true,
+		},
+		{
+			name:    "unauthorized",
+			status:  http.StatusUnauthorized,
+			body:    `{"errors": [{"reason": "Invalid Token"}]}`,
+			invalid: true,
+		},
+		{
+			name:    "not found",
+			status:  http.StatusNotFound,
+			body:    `{"errors": [{"reason": "Not found"}]}`,
+			invalid: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v4/lke/clusters/c1/kubeconfig" ||
+					r.Header.Get("Authorization") != "Bearer linode-token" {
+					http.Error(w, "unexpected request", http.StatusBadRequest)
+					return
+				}
+
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			t.Cleanup(srv.Close)
+
+			o := apitoken.TokenOptions{
+				APIToken: "linode-token",
+				Cluster:  "c1",
+				BaseURL:  srv.URL,
+			}
+
+			tk, err := Provider.GetToken(context.Background(), o, cache.NewNone())
+			if tc.invalid {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+
+				if tc.status != http.StatusOK && !strings.Contains(err.Error(), http.StatusText(tc.status)) {
+					t.Errorf("expected the error status %d, got %v", tc.status, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tk.Value != tc.expected {
+				t.Errorf("expected token %q, got %q", tc.expected, tk.Value)
+			}
+
+			if tc.certificate && (tk.ClientCertificateData != crt || tk.ClientKeyData != key) {
+				t.Error("expected the decoded client certificate and key")
+			}
+
+			if d := time.Until(tk.Expiration); d > tc.expiration || d < tc.expiration-time.Minute {
+				t.Errorf("expected expiring in %s, got %s", tc.expiration, d)
+			}
+		})
+	}
+}
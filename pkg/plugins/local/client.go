@@ -0,0 +1,173 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/apis"
+	"github.com/seal-io/kubecia/pkg/bytespool"
+	"github.com/seal-io/kubecia/pkg/cache"
+	"github.com/seal-io/kubecia/pkg/consts"
+	"github.com/seal-io/kubecia/pkg/token"
+	"github.com/seal-io/kubecia/pkg/version"
+)
+
+type Client struct {
+	Socket    string
+	Cache     string
+	Issuer    string
+	KeyPath   string
+	Subject   string
+	Groups    []string
+	Audiences []string
+	Lifetime  time.Duration
+}
+
+func (cli *Client) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&cli.Socket, "socket", consts.SocketPath(),
+		"Socket path or URL of the central service, e.g. /var/run/kubecia.sock or https://kubecia.example.com:8443")
+	flags.StringVar(&cli.Cache, "cache", "file://",
+		"Cache DSN, e.g. file:///path?buckets=12, memory:// or none://")
+	flags.StringVar(&cli.Issuer, "issuer", "",
+		"Issuer URL, signs locally if specified, otherwise the central service's issuer or "+DefaultIssuer)
+	flags.StringVar(&cli.KeyPath, "key-path", DefaultKeyPath(), "Signing key path")
+	flags.StringVar(&cli.Subject, "subject", "", "Token subject *")
+	flags.StringSliceVar(&cli.Groups, "group", nil, "Token groups")
+	flags.StringSliceVar(&cli.Audiences, "audience", nil, "Token audiences *")
+	flags.DurationVar(&cli.Lifetime, "lifetime", DefaultLifetime, "Token lifetime")
+}
+
+func (cli *Client) GetToken(ctx context.Context) (*token.Token, error) {
+	logger := klog.LoggerWithName(klog.Background(), Namespace)
+
+	// The central service signs with its own issuer,
+	// so only delegate if no issuer specified.
+	if apis.Served(cli.Socket) && cli.Issuer == "" {
+		logger.V(6).Info("getting from central service")
+
+		tk, err := cli.GetTokenByHTTP(ctx, apis.Client(cli.Socket))
+		if err == nil {
+			logger.V(6).Info("got from central service")
+
+			return tk, nil
+		}
+
+		var rce remoteCallError
+		if !errors.As(err, &rce) {
+			return nil, err
+		}
+
+		logger.Error(err, "error getting from central service, try getting locally")
+	} else {
+		logger.V(6).Info("getting locally")
+	}
+
+	tk, err := cli.getToken(ctx)
+	if err == nil {
+		logger.V(6).Info("got locally")
+
+		return tk, nil
+	}
+
+	return nil, fmt.Errorf("error getting token locally: %w", err)
+}
+
+func (cli *Client) GetTokenByHTTP(ctx context.Context, httpc *http.Client) (*token.Token, error) {
+	q := url.Values{}
+	for i := range cli.Audiences {
+		q.Add("audience", cli.Audiences[i])
+	}
+
+	for i := range cli.Groups {
+		q.Add("group", cli.Groups[i])
+	}
+
+	if cli.Lifetime != 0 {
+		q.Set("lifetime", cli.Lifetime.String())
+	}
+
+	url := apis.Route(Namespace, cli.Subject) + "?" + q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, wrapRemoteCallError(fmt.Errorf("error creating remote request: %w", err))
+	}
+
+	req.Header.Set("User-Agent", version.Get())
+	req.Header.Set("X-KubeCIA-DeCapsuled", "true")
+
+	resp, err := httpc.Do(req)
+	if err != nil {
+		return nil, wrapRemoteCallError(fmt.Errorf("error making remote request: %w", err))
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	// The central service may not enable the local issuer.
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, wrapRemoteCallError(fmt.Errorf("error response from remote: %s", resp.Status))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apis.ResponseError(resp)
+	}
+
+	buf := bytespool.GetBuffer()
+	defer bytespool.Put(buf)
+
+	_, err = io.Copy(buf, resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error copying response body: %w", err)
+	}
+
+	var tk token.Token
+	if err = tk.UnmarshalJSON(buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("error unmarshalling requested token: %w", err)
+	}
+
+	if resp.Header.Get("X-KubeCIA-Stale") == "true" {
+		tk.MarkStale()
+	}
+
+	return &tk, nil
+}
+
+func (cli *Client) getToken(ctx context.Context) (*token.Token, error) {
+	c, err := cache.Open(ctx, cli.Cache)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cache: %w", err)
+	}
+
+	defer func() { _ = c.Close() }()
+
+	o := TokenOptions{
+		Issuer:    cli.Issuer,
+		KeyPath:   cli.KeyPath,
+		Subject:   cli.Subject,
+		Groups:    cli.Groups,
+		Audiences: cli.Audiences,
+		Lifetime:  cli.Lifetime,
+	}
+
+	return GetToken(ctx, o, c)
+}
+
+func wrapRemoteCallError(err error) error {
+	return remoteCallError{err: err}
+}
+
+type remoteCallError struct {
+	err error
+}
+
+func (e remoteCallError) Error() string {
+	return e.err.Error()
+}
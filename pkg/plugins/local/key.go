@@ -0,0 +1,138 @@
+package local
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/seal-io/kubecia/pkg/consts"
+	"github.com/seal-io/kubecia/pkg/json"
+)
+
+// DefaultKeyPath returns the default path of the signing key.
+func DefaultKeyPath() string {
+	return filepath.Join(consts.DataDir(), Namespace, "signing.key")
+}
+
+// loadOrCreateKey loads the ECDSA P-256 signing key from the given path,
+// and generates one if not found.
+func loadOrCreateKey(path string) (*ecdsa.PrivateKey, error) {
+	bs, err := os.ReadFile(path)
+	if err == nil {
+		return parseKey(bs)
+	}
+
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading signing key: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("error creating signing key dir: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error generating signing key: %w", err)
+	}
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling signing key: %w", err)
+	}
+
+	// Create exclusively, another process may win the race.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		if os.IsExist(err) {
+			return loadOrCreateKey(path)
+		}
+
+		return nil, fmt.Errorf("error creating signing key: %w", err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	err = pem.Encode(f, &pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	if err != nil {
+		return nil, fmt.Errorf("error writing signing key: %w", err)
+	}
+
+	return key, nil
+}
+
+func parseKey(bs []byte) (*ecdsa.PrivateKey, error) {
+	b, _ := pem.Decode(bs)
+	if b == nil {
+		return nil, errors.New("invalid signing key: no PEM block found")
+	}
+
+	switch b.Type {
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(b.Bytes)
+	case "PRIVATE KEY":
+		k, err := x509.ParsePKCS8PrivateKey(b.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		if ek, ok := k.(*ecdsa.PrivateKey); ok && ek.Curve == elliptic.P256() {
+			return ek, nil
+		}
+	}
+
+	return nil, errors.New("invalid signing key: only ECDSA P-256 key is supported")
+}
+
+// jsonWebKey is the public part of the signing key in JWK format,
+// see https://www.rfc-editor.org/rfc/rfc7517.
+type jsonWebKey struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+}
+
+func toJSONWebKey(pub *ecdsa.PublicKey) jsonWebKey {
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	pad := func(i *big.Int) string {
+		b := make([]byte, size)
+		return base64.RawURLEncoding.EncodeToString(i.FillBytes(b))
+	}
+
+	jwk := jsonWebKey{
+		KeyType:   "EC",
+		Curve:     "P-256",
+		X:         pad(pub.X),
+		Y:         pad(pub.Y),
+		Use:       "sig",
+		Algorithm: "ES256",
+	}
+
+	// Key ID is the JWK thumbprint,
+	// see https://www.rfc-editor.org/rfc/rfc7638.
+	tp := `{"crv":"` + jwk.Curve + `","kty":"` + jwk.KeyType + `","x":"` + jwk.X + `","y":"` + jwk.Y + `"}`
+	h := sha256.Sum256([]byte(tp))
+	jwk.KeyID = base64.RawURLEncoding.EncodeToString(h[:])
+
+	return jwk
+}
+
+// marshalJSONWebKeySet returns the JWKS document of the given public key.
+func marshalJSONWebKeySet(pub *ecdsa.PublicKey) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"keys": []jsonWebKey{toJSONWebKey(pub)},
+	})
+}
@@ -0,0 +1,263 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/apis"
+	"github.com/seal-io/kubecia/pkg/apis/server"
+	"github.com/seal-io/kubecia/pkg/json"
+	"github.com/seal-io/kubecia/pkg/token"
+)
+
+const (
+	Namespace = "local"
+)
+
+// Server serves the local issuer,
+// which signs tokens and exposes the OIDC discovery documents.
+type Server struct {
+	Issuer  string
+	KeyPath string
+	// Subjects are the subjects allowed to sign, which accept the "*" wildcard,
+	// must be specified to enable the local provider.
+	Subjects []string
+	// Groups are the groups allowed to sign, which accept the "*" wildcard,
+	// the tokens are signed without groups if empty.
+	Groups []string
+}
+
+func (s *Server) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&s.Issuer, "local-issuer", "",
+		"Local issuer URL, enables the local provider and the OIDC discovery documents if specified")
+	flags.StringVar(&s.KeyPath, "local-key-path", DefaultKeyPath(), "Local issuer signing key path")
+	flags.StringSliceVar(&s.Subjects, "local-subjects", nil,
+		"Subjects allowed to sign by the local issuer, with * wildcard, repeatable, required if --local-issuer is specified")
+	flags.StringSliceVar(&s.Groups, "local-groups", nil,
+		"Groups allowed to sign by the local issuer, with * wildcard, repeatable, no groups are allowed if not specified")
+}
+
+func (s *Server) Serve(ctx context.Context, mux *http.ServeMux, opts server.ServeOptions) error {
+	if s.Issuer == "" {
+		return nil
+	}
+
+	u, err := url.Parse(s.Issuer)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("local issuer must be an HTTPS URL")
+	}
+
+	// Avoid signing any subject or group, e.g. system:masters.
+	if len(s.Subjects) == 0 {
+		return errors.New("local subjects are required to serve the local issuer")
+	}
+
+	key, err := loadOrCreateKey(s.KeyPath)
+	if err != nil {
+		return fmt.Errorf("error loading local signing key: %w", err)
+	}
+
+	jwks, err := marshalJSONWebKeySet(&key.PublicKey)
+	if err != nil {
+		return fmt.Errorf("error marshaling local json web key set: %w", err)
+	}
+
+	var (
+		discoveryPath = path.Join("/", u.Path, ".well-known", "openid-configuration")
+		jwksPath      = path.Join("/", u.Path, "openid", "v1", "jwks")
+	)
+
+	discovery, err := json.Marshal(map[string]any{
+		"issuer":                                s.Issuer,
+		"jwks_uri":                              strings.TrimSuffix(s.Issuer, "/") + path.Join("/openid", "v1", "jwks"),
+		"response_types_supported":              []string{"id_token"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"ES256"},
+	})
+	if err != nil {
+		return fmt.Errorf("error marshaling local discovery document: %w", err)
+	}
+
+	klog.Infof("serving %[1]s: /%[1]s/{subject}?audience=...[&group=...&lifetime=...]\n", Namespace)
+	klog.Infof("serving %s: %s, %s\n", Namespace, discoveryPath, jwksPath)
+
+	mux.Handle(discoveryPath, staticJSON(discovery))
+	mux.Handle(jwksPath, staticJSON(jwks))
+
+	rp := apis.RoutePrefix(Namespace)
+	hd := http.StripPrefix(rp, &apiServer{
+		ServeOptions: opts,
+		Logger:       klog.LoggerWithName(klog.Background(), Namespace),
+		Issuer:       s.Issuer,
+		KeyPath:      s.KeyPath,
+		Subjects:     server.NewWildcards(s.Subjects),
+		Groups:       server.NewWildcards(s.Groups),
+	})
+
+	mux.Handle(rp, hd)
+
+	return nil
+}
+
+type staticJSON []byte
+
+func (s staticJSON) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c := http.StatusMethodNotAllowed
+		http.Error(w, http.StatusText(c), c)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(s)
+}
+
+type apiServer struct {
+	server.ServeOptions
+
+	Logger   klog.Logger
+	Issuer   string
+	KeyPath  string
+	Subjects server.Wildcards
+	Groups   server.Wildcards
+}
+
+func (s *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c := http.StatusMethodNotAllowed
+		http.Error(w, http.StatusText(c), c)
+
+		return
+	}
+
+	o := TokenOptions{
+		Issuer:  s.Issuer,
+		KeyPath: s.KeyPath,
+	}
+
+	// Path: {subject}.
+	{
+		o.Subject = r.URL.Path
+		if o.Subject == "" {
+			c := http.StatusBadRequest
+			http.Error(w, http.StatusText(c), c)
+
+			return
+		}
+	}
+
+	// Query: audience=...[&group=...&lifetime=...].
+	{
+		q := r.URL.Query()
+
+		o.Audiences = q["audience"]
+		o.Groups = q["group"]
+
+		if v := q.Get("lifetime"); v != "" {
+			var err error
+
+			o.Lifetime, err = time.ParseDuration(v)
+			if err != nil {
+				c := http.StatusBadRequest
+				http.Error(w, http.StatusText(c), c)
+
+				return
+			}
+		}
+	}
+
+	if !s.allowed(o) {
+		s.Logger.Info("denied", "subject", o.Subject, "groups", o.Groups)
+
+		c := http.StatusForbidden
+		http.Error(w, http.StatusText(c), c)
+
+		return
+	}
+
+	if !s.Authorizer.Authorize(w, r, server.Attributes{
+		Provider: Namespace,
+		Role:     o.Subject,
+	}) {
+		return
+	}
+
+	// Validate ahead, so that the tracked and logged key is the one of the cached token.
+	if err := o.Validate(); err != nil {
+		http.Error(w, "invalid options: "+err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	key, err := o.Key()
+	if err != nil {
+		s.Logger.Error(err, "error keying token")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	tk, err := GetToken(r.Context(), o, s.Cache)
+	if err != nil {
+		server.ErrorToken(w, s.Logger, err)
+
+		return
+	}
+
+	s.Refresher.Track(key, tk, func(ctx context.Context) (*token.Token, error) {
+		return RefreshToken(ctx, o, s.Cache)
+	})
+
+	var bs []byte
+	if r.Header.Get("X-KubeCIA-DeCapsuled") == "true" {
+		bs, err = tk.MarshalJSON()
+	} else {
+		bs, err = tk.ToKubeClientExecCredentialJSON()
+	}
+
+	if err != nil {
+		s.Logger.Error(err, "error marshaling token")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if tk.Stale() {
+		w.Header().Set("X-KubeCIA-Stale", "true")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	_, err = w.Write(bs)
+	if err != nil {
+		s.Logger.Error(err, "error writing response")
+		return
+	}
+
+	server.LogIssued(s.Logger, r, key, tk)
+}
+
+// allowed returns true if the subject and all groups of the given options are allowed.
+func (s *apiServer) allowed(o TokenOptions) bool {
+	if !s.Subjects.Match(o.Subject) {
+		return false
+	}
+
+	for _, g := range o.Groups {
+		if !s.Groups.Match(g) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,59 @@
+package local
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/apis/server"
+	"github.com/seal-io/kubecia/pkg/cache"
+)
+
+func TestServeHTTP_allowed(t *testing.T) {
+	c, err := cache.Open(context.Background(), "memory://")
+	if err != nil {
+		t.Fatalf("error opening cache: %v", err)
+	}
+
+	t.Cleanup(func() { _ = c.Close() })
+
+	s := &apiServer{
+		ServeOptions: server.ServeOptions{Cache: c},
+		Logger:       klog.Background(),
+		Issuer:       "https://kubecia.example.com",
+		KeyPath:      filepath.Join(t.TempDir(), "local.key"),
+		Subjects:     server.NewWildcards([]string{"alice", "ci:*"}),
+		Groups:       server.NewWildcards([]string{"developers"}),
+	}
+
+	cases := []struct {
+		target   string
+		expected int
+	}{
+		{target: "alice?audience=kubernetes", expected: http.StatusOK},
+		{target: "alice?audience=kubernetes&group=developers", expected: http.StatusOK},
+		{target: "ci:deployer?audience=kubernetes", expected: http.StatusOK},
+		{target: "alice?audience=kubernetes&group=developers&group=system:masters", expected: http.StatusForbidden},
+		{target: "system:admin?audience=kubernetes", expected: http.StatusForbidden},
+		{target: "bob?audience=kubernetes", expected: http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.target, func(t *testing.T) {
+			// The handler is mounted behind http.StripPrefix.
+			r := httptest.NewRequest(http.MethodGet, "/"+tc.target, nil)
+			r.URL.Path = r.URL.Path[1:]
+
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+
+			if w.Code != tc.expected {
+				t.Errorf("expected %d, got %d: %s", tc.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
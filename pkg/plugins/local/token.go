@@ -0,0 +1,212 @@
+package local
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/cache"
+	"github.com/seal-io/kubecia/pkg/token"
+)
+
+const (
+	// DefaultIssuer is the default issuer of the signed token.
+	DefaultIssuer = "https://kubecia.local"
+
+	// DefaultLifetime is the default lifetime of the signed token.
+	DefaultLifetime = 1 * time.Hour
+
+	maxLifetime = 24 * time.Hour
+)
+
+type TokenOptions struct {
+	// Issuer is the issuer of the token,
+	// must be an HTTPS URL trusted by the API server,
+	// default is DefaultIssuer.
+	Issuer string
+	// KeyPath is the path of the signing key,
+	// default is DefaultKeyPath.
+	KeyPath string
+
+	Subject   string
+	Groups    []string
+	Audiences []string
+	// Lifetime is the lifetime of the token,
+	// default is DefaultLifetime.
+	Lifetime time.Duration
+}
+
+func (o *TokenOptions) Validate() error {
+	if o.Issuer == "" {
+		o.Issuer = DefaultIssuer
+	}
+
+	if u, err := url.Parse(o.Issuer); err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("issuer must be an HTTPS URL")
+	}
+
+	if o.KeyPath == "" {
+		o.KeyPath = DefaultKeyPath()
+	}
+
+	if o.Subject == "" {
+		return errors.New("subject is required")
+	}
+
+	if len(o.Audiences) == 0 {
+		return errors.New("audience is required")
+	}
+
+	if o.Lifetime == 0 {
+		o.Lifetime = DefaultLifetime
+	}
+
+	if o.Lifetime < time.Minute || o.Lifetime > maxLifetime {
+		return fmt.Errorf("lifetime must be between 1m and %s", maxLifetime)
+	}
+
+	return nil
+}
+
+func (o *TokenOptions) Key() (string, error) {
+	grps := make([]string, len(o.Groups))
+	copy(grps, o.Groups)
+	sort.Strings(grps)
+
+	auds := make([]string, len(o.Audiences))
+	copy(auds, o.Audiences)
+	sort.Strings(auds)
+
+	// Hash the claims to keep the key short and path safe.
+	d, err := cache.Digest(
+		Namespace,
+		o.Issuer, o.KeyPath,
+		o.Subject, strings.Join(grps, ","), strings.Join(auds, ","), o.Lifetime.String(),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	ss := []string{
+		Namespace,
+		d,
+	}
+
+	return strings.Join(ss, "_"), nil
+}
+
+// Describe returns the description of the token requested with the options.
+func (o *TokenOptions) Describe() token.Description {
+	return token.Description{
+		Identity: o.Subject,
+	}
+}
+
+// GetToken retrieves a token from cache or remote.
+func GetToken(ctx context.Context, opts TokenOptions, cacher cache.Cache) (*token.Token, error) {
+	logger := klog.LoggerWithName(klog.Background(), Namespace)
+
+	err := opts.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+
+	ctx = token.WithDescription(ctx, opts.Describe())
+
+	key, err := opts.Key()
+	if err != nil {
+		return nil, fmt.Errorf("error keying token: %w", err)
+	}
+
+	return token.GetCached(ctx, logger, cacher, key, fetchToken(opts))
+}
+
+// RefreshToken requests a token from remote and saves it into cache,
+// ignores the cached one.
+func RefreshToken(ctx context.Context, opts TokenOptions, cacher cache.Cache) (*token.Token, error) {
+	logger := klog.LoggerWithName(klog.Background(), Namespace)
+
+	err := opts.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+
+	ctx = token.WithDescription(ctx, opts.Describe())
+
+	key, err := opts.Key()
+	if err != nil {
+		return nil, fmt.Errorf("error keying token: %w", err)
+	}
+
+	return token.Refresh(ctx, logger, cacher, key, fetchToken(opts))
+}
+
+func fetchToken(opts TokenOptions) token.FetchFunc {
+	return func(ctx context.Context) (*token.Token, error) {
+		tk, err := getToken(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error signing token: %w", err)
+		}
+
+		return tk, nil
+	}
+}
+
+// claims is the payload of the signed token,
+// groups are mapped by the claim mappings of the API server's structured authentication configuration.
+type claims struct {
+	jwt.RegisteredClaims
+
+	Groups []string `json:"groups,omitempty"`
+}
+
+// getToken returns the token signed by the local key.
+func getToken(_ context.Context, opts TokenOptions) (*token.Token, error) {
+	key, err := loadOrCreateKey(opts.KeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	jti := make([]byte, 16)
+	if _, err = rand.Read(jti); err != nil {
+		return nil, fmt.Errorf("error generating token ID: %w", err)
+	}
+
+	now := time.Now()
+	exp := now.Add(opts.Lifetime)
+
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    opts.Issuer,
+			Subject:   opts.Subject,
+			Audience:  opts.Audiences,
+			ExpiresAt: jwt.NewNumericDate(exp),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        hex.EncodeToString(jti),
+		},
+		Groups: opts.Groups,
+	})
+	t.Header["kid"] = toJSONWebKey(&key.PublicKey).KeyID
+
+	v, err := t.SignedString(key)
+	if err != nil {
+		return nil, err
+	}
+
+	tk := &token.Token{
+		Expiration: exp,
+		Value:      v,
+	}
+
+	return tk, nil
+}
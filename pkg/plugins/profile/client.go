@@ -0,0 +1,95 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/apis"
+	"github.com/seal-io/kubecia/pkg/bytespool"
+	"github.com/seal-io/kubecia/pkg/consts"
+	"github.com/seal-io/kubecia/pkg/token"
+	"github.com/seal-io/kubecia/pkg/version"
+)
+
+type Client struct {
+	Socket string
+	Name   string
+}
+
+func (cli *Client) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&cli.Socket, "socket", consts.SocketPath(),
+		"Socket path or URL of the central service, e.g. /var/run/kubecia.sock or https://kubecia.example.com:8443")
+	flags.StringVar(&cli.Name, "name", "", "Profile name *")
+}
+
+func (cli *Client) GetToken(ctx context.Context) (*token.Token, error) {
+	logger := klog.LoggerWithName(klog.Background(), Namespace)
+
+	if cli.Name == "" {
+		return nil, errors.New("blank profile name")
+	}
+
+	// The profiles are only resolvable by the central service.
+	if !apis.Served(cli.Socket) {
+		return nil, fmt.Errorf("error getting profile %q: central service is not serving", cli.Name)
+	}
+
+	logger.V(6).Info("getting from central service")
+
+	tk, err := cli.GetTokenByHTTP(ctx, apis.Client(cli.Socket))
+	if err != nil {
+		return nil, err
+	}
+
+	logger.V(6).Info("got from central service")
+
+	return tk, nil
+}
+
+func (cli *Client) GetTokenByHTTP(ctx context.Context, httpc *http.Client) (*token.Token, error) {
+	url := apis.Route(Namespace, cli.Name)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating remote request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", version.Get())
+	req.Header.Set("X-KubeCIA-DeCapsuled", "true")
+
+	resp, err := httpc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making remote request: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apis.ResponseError(resp)
+	}
+
+	buf := bytespool.GetBuffer()
+	defer bytespool.Put(buf)
+
+	_, err = io.Copy(buf, resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error copying response body: %w", err)
+	}
+
+	var tk token.Token
+	if err = tk.UnmarshalJSON(buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("error unmarshalling requested token: %w", err)
+	}
+
+	if resp.Header.Get("X-KubeCIA-Stale") == "true" {
+		tk.MarkStale()
+	}
+
+	return &tk, nil
+}
@@ -0,0 +1,168 @@
+package profile
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Profile is a named credential resolved by the central service,
+// so that the clients request the token without holding the secrets.
+type Profile struct {
+	// Name is the name of the profile,
+	// default is the file name without extension if loaded from a directory.
+	Name string `json:"name"`
+	// Subjects are the callers allowed to use the profile,
+	// which accepts the same subjects as the authorization policy, e.g. "uid:1000" or "spiffe://example.org/ci".
+	Subjects []string `json:"subjects"`
+
+	// Provider is the provider to request, e.g. "aws".
+	Provider string `json:"provider"`
+	// Path is the route path of the provider, e.g. "{region}/{cluster}[/{assume-role-arn}]" of aws,
+	// the segment containing slashes is escaped, e.g. "kubernetes/kv%2Fteam/{role}" of vault.
+	Path string `json:"path"`
+	// Query is the route query of the provider, e.g. {"audience": ["..."]} of local.
+	Query url.Values `json:"query,omitempty"`
+
+	// Username and Password are the basic credential of the provider,
+	// e.g. the access key ID and secret access key of aws.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// BearerToken is the bearer credential of the provider,
+	// e.g. the API token of digitalocean.
+	BearerToken string `json:"bearerToken,omitempty"`
+}
+
+var nameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+func (p *Profile) Validate() error {
+	if !nameRegex.MatchString(p.Name) {
+		return fmt.Errorf("invalid profile name %q", p.Name)
+	}
+
+	if len(p.Subjects) == 0 {
+		return fmt.Errorf("invalid profile %q: blank subjects", p.Name)
+	}
+
+	switch p.Provider {
+	case "":
+		return fmt.Errorf("invalid profile %q: blank provider", p.Name)
+	case Namespace, "admin":
+		return fmt.Errorf("invalid profile %q: unsupported provider %q", p.Name, p.Provider)
+	}
+
+	if _, err := url.PathUnescape(p.Path); err != nil {
+		return fmt.Errorf("invalid profile %q: invalid path: %w", p.Name, err)
+	}
+
+	if p.BearerToken != "" && (p.Username != "" || p.Password != "") {
+		return fmt.Errorf("invalid profile %q: both basic and bearer credentials specified", p.Name)
+	}
+
+	return nil
+}
+
+// loadProfiles loads the profiles from the given path,
+// which is a file with a list of profiles,
+// or a directory of the profile files, e.g. the mounted secrets.
+func loadProfiles(p string) (map[string]*Profile, []byte, error) {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error stating profiles: %w", err)
+	}
+
+	var (
+		ps []*Profile
+		h  = sha256.New()
+	)
+
+	if !fi.IsDir() {
+		bs, err := os.ReadFile(p)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading profiles: %w", err)
+		}
+
+		_, _ = h.Write(bs)
+
+		var l struct {
+			Profiles []*Profile `json:"profiles"`
+		}
+		if err = yaml.UnmarshalStrict(bs, &l); err != nil {
+			return nil, nil, fmt.Errorf("error decoding profiles %s: %w", p, err)
+		}
+
+		ps = l.Profiles
+	} else {
+		des, err := os.ReadDir(p)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading profiles: %w", err)
+		}
+
+		for _, de := range des {
+			n := de.Name()
+
+			// Skip the hidden entries, e.g. the "..data" of the mounted secrets.
+			if strings.HasPrefix(n, ".") {
+				continue
+			}
+
+			ext := filepath.Ext(n)
+			if ext != ".yaml" && ext != ".yml" && ext != ".json" {
+				continue
+			}
+
+			// Follow the symlinks of the mounted secrets.
+			f := filepath.Join(p, n)
+
+			if fi, err := os.Stat(f); err != nil || !fi.Mode().IsRegular() {
+				continue
+			}
+
+			bs, err := os.ReadFile(f)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error reading profile: %w", err)
+			}
+
+			_, _ = h.Write([]byte(n))
+			_, _ = h.Write(bs)
+
+			var pf Profile
+			if err = yaml.UnmarshalStrict(bs, &pf); err != nil {
+				return nil, nil, fmt.Errorf("error decoding profile %s: %w", f, err)
+			}
+
+			if pf.Name == "" {
+				pf.Name = strings.TrimSuffix(n, ext)
+			}
+
+			ps = append(ps, &pf)
+		}
+	}
+
+	m := make(map[string]*Profile, len(ps))
+
+	for i := range ps {
+		if ps[i] == nil {
+			return nil, nil, errors.New("invalid profile: blank")
+		}
+
+		if err = ps[i].Validate(); err != nil {
+			return nil, nil, err
+		}
+
+		if _, exist := m[ps[i].Name]; exist {
+			return nil, nil, fmt.Errorf("invalid profile %q: duplicated", ps[i].Name)
+		}
+
+		m[ps[i].Name] = ps[i]
+	}
+
+	return m, h.Sum(nil), nil
+}
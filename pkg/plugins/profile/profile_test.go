@@ -0,0 +1,144 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles writes the given files under the given directory.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for n, c := range files {
+		if err := os.WriteFile(filepath.Join(dir, n), []byte(c), 0o600); err != nil {
+			t.Fatalf("error writing %s: %v", n, err)
+		}
+	}
+}
+
+func TestLoadProfiles_file(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "profiles.yaml")
+
+	writeFiles(t, filepath.Dir(p), map[string]string{
+		"profiles.yaml": `
+profiles:
+  - name: prod-aws
+    subjects: ["uid:1000"]
+    provider: aws
+    path: us-east-1/prod
+    username: AKIA
+    password: secret
+  - name: do
+    subjects: ["*"]
+    provider: digitalocean
+    path: cluster
+    bearerToken: token
+`,
+	})
+
+	ps, dg, err := loadProfiles(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(ps) != 2 || ps["prod-aws"] == nil || ps["do"] == nil {
+		t.Fatalf("expected prod-aws and do, got %v", ps)
+	}
+
+	if ps["prod-aws"].Password != "secret" || ps["do"].BearerToken != "token" {
+		t.Errorf("unexpected credentials: %+v, %+v", ps["prod-aws"], ps["do"])
+	}
+
+	// The digest is stable.
+	_, dg2, err := loadProfiles(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(dg) != string(dg2) {
+		t.Error("expected the same digest of the unchanged profiles")
+	}
+}
+
+func TestLoadProfiles_dir(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFiles(t, dir, map[string]string{
+		"prod-aws.yaml": "subjects: [\"uid:1000\"]\nprovider: aws\npath: us-east-1/prod\n",
+		"named.json":    `{"name": "other", "subjects": ["*"], "provider": "gcp", "path": "region/cluster"}`,
+		"README.md":     "not a profile",
+		".hidden.yaml":  "invalid: [",
+	})
+
+	// The data dir of the mounted secrets.
+	if err := os.Mkdir(filepath.Join(dir, "..data"), 0o700); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ps, _, err := loadProfiles(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(ps) != 2 || ps["prod-aws"] == nil || ps["other"] == nil {
+		t.Errorf("expected prod-aws and other, got %v", ps)
+	}
+}
+
+func TestLoadProfiles_invalid(t *testing.T) {
+	cases := []struct {
+		name  string
+		files map[string]string
+	}{
+		{
+			name:  "blank subjects",
+			files: map[string]string{"p.yaml": "provider: aws\n"},
+		},
+		{
+			name:  "blank provider",
+			files: map[string]string{"p.yaml": "subjects: [\"*\"]\n"},
+		},
+		{
+			name:  "recursive provider",
+			files: map[string]string{"p.yaml": "subjects: [\"*\"]\nprovider: profiles\n"},
+		},
+		{
+			name:  "admin provider",
+			files: map[string]string{"p.yaml": "subjects: [\"*\"]\nprovider: admin\n"},
+		},
+		{
+			name: "both credentials",
+			files: map[string]string{
+				"p.yaml": "subjects: [\"*\"]\nprovider: aws\nusername: u\nbearerToken: t\n",
+			},
+		},
+		{
+			name:  "invalid name",
+			files: map[string]string{"p.yaml": "name: -p\nsubjects: [\"*\"]\nprovider: aws\n"},
+		},
+		{
+			name: "duplicated name",
+			files: map[string]string{
+				"a.yaml": "name: p\nsubjects: [\"*\"]\nprovider: aws\n",
+				"b.yaml": "name: p\nsubjects: [\"*\"]\nprovider: gcp\n",
+			},
+		},
+		{
+			name:  "unknown field",
+			files: map[string]string{"p.yaml": "subjects: [\"*\"]\nprovider: aws\nsecret: s\n"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+
+			_, _, err := loadProfiles(dir)
+			if err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
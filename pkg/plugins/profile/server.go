@@ -0,0 +1,169 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/apis"
+	"github.com/seal-io/kubecia/pkg/apis/server"
+)
+
+const (
+	Namespace = "profiles"
+
+	reloadInterval = 10 * time.Second
+)
+
+// Server serves the named profiles,
+// which resolves the provider, credential and route of the profile on behalf of the clients.
+type Server struct {
+	Path string
+	// ReloadInterval indicates the interval to check the changes of the profiles,
+	// default is 10 seconds.
+	ReloadInterval time.Duration
+}
+
+func (s *Server) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&s.Path, "profiles", "",
+		"Profiles file, or directory of the profile files, e.g. the mounted secrets, "+
+			"enables the profile provider if specified, reloaded once changed")
+}
+
+func (s *Server) Serve(ctx context.Context, mux *http.ServeMux, opts server.ServeOptions) error {
+	if s.Path == "" {
+		return nil
+	}
+
+	ps, dg, err := loadProfiles(s.Path)
+	if err != nil {
+		return fmt.Errorf("error loading profiles: %w", err)
+	}
+
+	klog.Infof("serving %[1]s: /%[1]s/{name}\n", Namespace)
+
+	as := &apiServer{
+		ServeOptions: opts,
+		Logger:       klog.LoggerWithName(klog.Background(), Namespace),
+		Mux:          mux,
+	}
+	as.store(ps)
+
+	interval := s.ReloadInterval
+	if interval == 0 {
+		interval = reloadInterval
+	}
+
+	go func() {
+		_ = wait.PollUntilContextCancel(ctx, interval, false, func(ctx context.Context) (bool, error) {
+			ps, ndg, err := loadProfiles(s.Path)
+			if err != nil {
+				// Keep serving the previous profiles,
+				// the files may be in the middle of updating.
+				as.Logger.Error(err, "error reloading profiles")
+				return false, nil
+			}
+
+			if !bytes.Equal(dg, ndg) {
+				as.store(ps)
+				dg = ndg
+
+				as.Logger.Info("reloaded profiles", "count", len(ps))
+			}
+
+			return false, nil
+		})
+	}()
+
+	rp := apis.RoutePrefix(Namespace)
+	hd := http.StripPrefix(rp, as)
+
+	mux.Handle(rp, hd)
+
+	return nil
+}
+
+type apiServer struct {
+	server.ServeOptions
+
+	Logger klog.Logger
+	// Mux dispatches the resolved request to the provider.
+	Mux *http.ServeMux
+
+	profiles atomic.Pointer[map[string]resolvedProfile]
+}
+
+type resolvedProfile struct {
+	*Profile
+
+	subjects server.SubjectMatcher
+}
+
+func (s *apiServer) store(ps map[string]*Profile) {
+	m := make(map[string]resolvedProfile, len(ps))
+	for n := range ps {
+		m[n] = resolvedProfile{
+			Profile:  ps[n],
+			subjects: server.NewSubjectMatcher(ps[n].Subjects),
+		}
+	}
+
+	s.profiles.Store(&m)
+}
+
+func (s *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c := http.StatusMethodNotAllowed
+		http.Error(w, http.StatusText(c), c)
+
+		return
+	}
+
+	// Path: {name}.
+	name := strings.Trim(r.URL.Path, "/")
+
+	p, ok := (*s.profiles.Load())[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !p.subjects.Match(r) {
+		s.Logger.Info("denied", "profile", name, "subjects", server.RequestSubjects(r))
+
+		c := http.StatusForbidden
+		http.Error(w, http.StatusText(c), c)
+
+		return
+	}
+
+	// Dispatch to the provider with the credential of the profile,
+	// the authorization policy still applies to the caller.
+	pr := r.Clone(r.Context())
+	pr.URL.RawPath = apis.RoutePrefix(p.Provider) + strings.TrimPrefix(path.Clean("/"+p.Path), "/")
+	pr.URL.Path, _ = url.PathUnescape(pr.URL.RawPath)
+	pr.URL.RawQuery = p.Query.Encode()
+
+	pr.Header.Del("Authorization")
+
+	switch {
+	case p.BearerToken != "":
+		pr.Header.Set("Authorization", "Bearer "+p.BearerToken)
+	case p.Username != "" || p.Password != "":
+		pr.SetBasicAuth(p.Username, p.Password)
+	}
+
+	s.Logger.V(4).Info("resolved", "profile", name, "provider", p.Provider)
+
+	s.Mux.ServeHTTP(w, pr)
+}
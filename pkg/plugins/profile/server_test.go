@@ -0,0 +1,251 @@
+package profile
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seal-io/kubecia/pkg/apis"
+	"github.com/seal-io/kubecia/pkg/apis/server"
+	"github.com/seal-io/kubecia/pkg/cache"
+	"github.com/seal-io/kubecia/pkg/plugins/aws"
+)
+
+// fakeProvider records the dispatched requests.
+type fakeProvider struct {
+	mu   sync.Mutex
+	reqs []*http.Request
+}
+
+func (p *fakeProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.reqs = append(p.reqs, r)
+	p.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *fakeProvider) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.reqs)
+}
+
+func (p *fakeProvider) last() *http.Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.reqs) == 0 {
+		return nil
+	}
+
+	return p.reqs[len(p.reqs)-1]
+}
+
+// newMux returns the ServeMux serving the profiles of the given directory,
+// and dispatching them to a fake provider.
+func newMux(t *testing.T, dir string, interval time.Duration) (*http.ServeMux, *fakeProvider) {
+	t.Helper()
+
+	var (
+		mux = http.NewServeMux()
+		fp  = &fakeProvider{}
+	)
+
+	mux.Handle(apis.RoutePrefix("fake"), fp)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	s := Server{Path: dir, ReloadInterval: interval}
+
+	err := s.Serve(ctx, mux, server.ServeOptions{})
+	if err != nil {
+		t.Fatalf("error serving: %v", err)
+	}
+
+	return mux, fp
+}
+
+func get(mux *http.ServeMux, name string) int {
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+Namespace+"/"+name, nil))
+
+	return w.Code
+}
+
+func TestServer_dispatch(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFiles(t, dir, map[string]string{
+		"basic.yaml": `
+subjects: ["system:anonymous"]
+provider: fake
+path: region/../cluster/role
+query: {audience: ["a", "b"]}
+username: id
+password: secret
+`,
+		"bearer.yaml": "subjects: [\"system:*\"]\nprovider: fake\npath: cluster\nbearerToken: token\n",
+		"denied.yaml": "subjects: [\"uid:12345\"]\nprovider: fake\npath: cluster\nbearerToken: token\n",
+	})
+
+	mux, fp := newMux(t, dir, 0)
+
+	if c := get(mux, "basic"); c != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", c)
+	}
+
+	r := fp.last()
+
+	if r.URL.Path != "/fake/cluster/role" {
+		t.Errorf("expected the cleaned path /fake/cluster/role, got %s", r.URL.Path)
+	}
+
+	if r.URL.RawQuery != "audience=a&audience=b" {
+		t.Errorf("expected the query of the profile, got %s", r.URL.RawQuery)
+	}
+
+	if u, p, ok := r.BasicAuth(); !ok || u != "id" || p != "secret" {
+		t.Errorf("expected the basic credential of the profile, got %q, %q", u, p)
+	}
+
+	if c := get(mux, "bearer"); c != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", c)
+	}
+
+	if a := fp.last().Header.Get("Authorization"); a != "Bearer token" {
+		t.Errorf("expected the bearer credential of the profile, got %q", a)
+	}
+
+	// Deny the caller out of the subjects.
+	n := fp.count()
+
+	if c := get(mux, "denied"); c != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", c)
+	}
+
+	if c := get(mux, "missing"); c != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", c)
+	}
+
+	if fp.count() != n {
+		t.Errorf("expected no dispatching of the denied or missing profile, got %d", fp.count()-n)
+	}
+}
+
+func TestServer_dispatch_credential(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFiles(t, dir, map[string]string{
+		"p.yaml": "subjects: [\"*\"]\nprovider: fake\npath: cluster\n",
+	})
+
+	mux, fp := newMux(t, dir, 0)
+
+	// Never pass through the credential of the caller.
+	req := httptest.NewRequest(http.MethodGet, "/"+Namespace+"/p", nil)
+	req.SetBasicAuth("caller", "secret")
+
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	if r := fp.last(); r == nil || r.Header.Get("Authorization") != "" {
+		t.Errorf("expected the credential of the caller removed, got %v", r)
+	}
+}
+
+func TestServer_reload(t *testing.T) {
+	const interval = 20 * time.Millisecond
+
+	dir := t.TempDir()
+
+	writeFiles(t, dir, map[string]string{
+		"p.yaml": "subjects: [\"*\"]\nprovider: fake\npath: first\n",
+	})
+
+	mux, fp := newMux(t, dir, interval)
+
+	if c := get(mux, "p"); c != http.StatusNoContent || fp.last().URL.Path != "/fake/first" {
+		t.Fatalf("expected dispatching to /fake/first, got %d", c)
+	}
+
+	// Keep serving the previous profiles if the changed files are invalid.
+	writeFiles(t, dir, map[string]string{
+		"p.yaml": "subjects: [\"*\"]\nprovider: admin\n",
+	})
+
+	time.Sleep(5 * interval)
+
+	if c := get(mux, "p"); c != http.StatusNoContent || fp.last().URL.Path != "/fake/first" {
+		t.Fatalf("expected dispatching to /fake/first, got %d", c)
+	}
+
+	writeFiles(t, dir, map[string]string{
+		"p.yaml": "subjects: [\"*\"]\nprovider: fake\npath: second\n",
+		"q.yaml": "subjects: [\"*\"]\nprovider: fake\npath: third\n",
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		if c := get(mux, "p"); c != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d", c)
+		}
+
+		if fp.last().URL.Path == "/fake/second" {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("expected reloading the changed profiles, got timeout")
+		}
+
+		time.Sleep(interval)
+	}
+
+	if c := get(mux, "q"); c != http.StatusNoContent {
+		t.Errorf("expected the added profile served, got %d", c)
+	}
+}
+
+func TestServer_dispatch_expandEnv(t *testing.T) {
+	t.Setenv("KUBECIA_TEST_AWS_SECRET", "secret")
+
+	dir := t.TempDir()
+
+	writeFiles(t, dir, map[string]string{
+		"p.yaml": `
+subjects: ["*"]
+provider: aws
+path: us-east-1/prod/arn:aws:iam::123456789012:role/ci
+username: AKIA
+password: $KUBECIA_TEST_AWS_SECRET
+`,
+	})
+
+	var (
+		mux = http.NewServeMux()
+		o   = server.ServeOptions{Cache: cache.NewNone()}
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	if err := aws.Serve(ctx, mux, o); err != nil {
+		t.Fatalf("error serving: %v", err)
+	}
+
+	s := Server{Path: dir}
+	if err := s.Serve(ctx, mux, o); err != nil {
+		t.Fatalf("error serving: %v", err)
+	}
+
+	// Refuse expanding the environment of the service at default.
+	if c := get(mux, "p"); c != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", c)
+	}
+}
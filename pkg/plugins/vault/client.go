@@ -0,0 +1,231 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/apis"
+	"github.com/seal-io/kubecia/pkg/bytespool"
+	"github.com/seal-io/kubecia/pkg/cache"
+	"github.com/seal-io/kubecia/pkg/consts"
+	"github.com/seal-io/kubecia/pkg/token"
+	"github.com/seal-io/kubecia/pkg/version"
+)
+
+type Client struct {
+	Socket              string
+	Cache               string
+	Address             string
+	VaultNamespace      string
+	AuthMethod          string
+	AuthMount           string
+	Token               string
+	RoleID              string
+	SecretID            string
+	AuthRole            string
+	JWTPath             string
+	Engine              string
+	Mount               string
+	Role                string
+	KubernetesNamespace string
+	CommonName          string
+	TTL                 time.Duration
+}
+
+func (cli *Client) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&cli.Socket, "socket", consts.SocketPath(),
+		"Socket path or URL of the central service, e.g. /var/run/kubecia.sock or https://kubecia.example.com:8443")
+	flags.StringVar(&cli.Cache, "cache", "file://",
+		"Cache DSN, e.g. file:///path?buckets=12, memory:// or none://")
+	flags.StringVar(&cli.Address, "address", "",
+		"Vault address, default is $VAULT_ADDR or "+DefaultAddress+", which is only supported when getting locally")
+	flags.StringVar(&cli.VaultNamespace, "vault-namespace", "", "Vault Enterprise namespace")
+	flags.StringVar(&cli.AuthMethod, "auth-method", AuthMethodToken,
+		"Vault auth method, select from token, approle and kubernetes *")
+	flags.StringVar(&cli.AuthMount, "auth-mount", "", "Vault auth method mount path")
+	flags.StringVar(&cli.Token, "token", "", "Vault token, required by token auth method")
+	flags.StringVar(&cli.RoleID, "role-id", "", "Vault AppRole role ID, required by approle auth method")
+	flags.StringVar(&cli.SecretID, "secret-id", "", "Vault AppRole secret ID, required by approle auth method")
+	flags.StringVar(&cli.AuthRole, "auth-role", "", "Vault Kubernetes auth role, required by kubernetes auth method")
+	flags.StringVar(&cli.JWTPath, "jwt-path", DefaultKubernetesJWTPath,
+		"Service account token path, used by kubernetes auth method")
+	flags.StringVar(&cli.Engine, "engine", EngineKubernetes,
+		"Vault secrets engine, select from kubernetes and pki *")
+	flags.StringVar(&cli.Mount, "mount", "", "Vault secrets engine mount path")
+	flags.StringVar(&cli.Role, "role", "", "Vault secrets engine role *")
+	flags.StringVar(&cli.KubernetesNamespace, "kubernetes-namespace", "",
+		"Kubernetes namespace, required by kubernetes secrets engine")
+	flags.StringVar(&cli.CommonName, "common-name", "",
+		"Client certificate common name, required by pki secrets engine")
+	flags.DurationVar(&cli.TTL, "ttl", 0, "Requested credential TTL")
+}
+
+func (cli *Client) GetToken(ctx context.Context) (*token.Token, error) {
+	logger := klog.LoggerWithName(klog.Background(), Namespace)
+
+	// The central service cannot log in with the kubernetes auth method on behalf of the caller.
+	if apis.Served(cli.Socket) &&
+		cli.AuthMethod != AuthMethodKubernetes {
+		// The central service requests its own Vault only,
+		// never replace the specified one silently.
+		if cli.Address != "" {
+			return nil, errors.New("invalid options: --address is not supported by the central service, " +
+				"specify --socket=\"\" to get locally")
+		}
+
+		logger.V(6).Info("getting from central service")
+
+		tk, err := cli.GetTokenByHTTP(ctx, apis.Client(cli.Socket))
+		if err == nil {
+			logger.V(6).Info("got from central service")
+
+			return tk, nil
+		}
+
+		var rce remoteCallError
+		if !errors.As(err, &rce) {
+			return nil, err
+		}
+
+		logger.Error(err, "error getting from central service, try getting locally")
+	} else {
+		logger.V(6).Info("getting locally")
+	}
+
+	tk, err := cli.getToken(ctx)
+	if err == nil {
+		logger.V(6).Info("got locally")
+
+		return tk, nil
+	}
+
+	return nil, fmt.Errorf("error getting token locally: %w", err)
+}
+
+func (cli *Client) GetTokenByHTTP(ctx context.Context, httpc *http.Client) (*token.Token, error) {
+	mount := cli.Mount
+	if mount == "" {
+		mount = cli.Engine
+	}
+
+	q := url.Values{}
+	if cli.AuthMount != "" {
+		q.Set("authMount", cli.AuthMount)
+	}
+
+	if cli.VaultNamespace != "" {
+		q.Set("vaultNamespace", cli.VaultNamespace)
+	}
+
+	if cli.KubernetesNamespace != "" {
+		q.Set("kubernetesNamespace", cli.KubernetesNamespace)
+	}
+
+	if cli.CommonName != "" {
+		q.Set("commonName", cli.CommonName)
+	}
+
+	if cli.TTL != 0 {
+		q.Set("ttl", cli.TTL.String())
+	}
+
+	// Escape the nested mount path, e.g. kv/team.
+	url := apis.Route(Namespace, cli.Engine, url.PathEscape(mount), cli.Role)
+	if len(q) != 0 {
+		url += "?" + q.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, wrapRemoteCallError(fmt.Errorf("error creating remote request: %w", err))
+	}
+
+	switch cli.AuthMethod {
+	case AuthMethodAppRole:
+		req.SetBasicAuth(cli.RoleID, cli.SecretID)
+	default:
+		req.Header.Set("Authorization", "Bearer "+cli.Token)
+	}
+
+	req.Header.Set("User-Agent", version.Get())
+	req.Header.Set("X-KubeCIA-DeCapsuled", "true")
+
+	resp, err := httpc.Do(req)
+	if err != nil {
+		return nil, wrapRemoteCallError(fmt.Errorf("error making remote request: %w", err))
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apis.ResponseError(resp)
+	}
+
+	buf := bytespool.GetBuffer()
+	defer bytespool.Put(buf)
+
+	_, err = io.Copy(buf, resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error copying response body: %w", err)
+	}
+
+	var tk token.Token
+	if err = tk.UnmarshalJSON(buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("error unmarshalling requested token: %w", err)
+	}
+
+	if resp.Header.Get("X-KubeCIA-Stale") == "true" {
+		tk.MarkStale()
+	}
+
+	return &tk, nil
+}
+
+func (cli *Client) getToken(ctx context.Context) (*token.Token, error) {
+	c, err := cache.Open(ctx, cli.Cache)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cache: %w", err)
+	}
+
+	defer func() { _ = c.Close() }()
+
+	o := TokenOptions{
+		Address:             cli.Address,
+		VaultNamespace:      cli.VaultNamespace,
+		AuthMethod:          cli.AuthMethod,
+		AuthMount:           cli.AuthMount,
+		Token:               cli.Token,
+		RoleID:              cli.RoleID,
+		SecretID:            cli.SecretID,
+		AuthRole:            cli.AuthRole,
+		JWTPath:             cli.JWTPath,
+		Engine:              cli.Engine,
+		Mount:               cli.Mount,
+		Role:                cli.Role,
+		KubernetesNamespace: cli.KubernetesNamespace,
+		CommonName:          cli.CommonName,
+		TTL:                 cli.TTL,
+	}
+
+	return GetToken(ctx, o, c)
+}
+
+func wrapRemoteCallError(err error) error {
+	return remoteCallError{err: err}
+}
+
+type remoteCallError struct {
+	err error
+}
+
+func (e remoteCallError) Error() string {
+	return e.err.Error()
+}
@@ -0,0 +1,184 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/apis"
+	"github.com/seal-io/kubecia/pkg/apis/server"
+	"github.com/seal-io/kubecia/pkg/token"
+)
+
+const (
+	Namespace = "vault"
+)
+
+func Serve(ctx context.Context, mux *http.ServeMux, opts server.ServeOptions) error {
+	klog.Infof("serving %[1]s: /%[1]s/{engine}/{mount}/{role}"+
+		"[?kubernetesNamespace=...&commonName=...&ttl=...&authMount=...&vaultNamespace=...]\n", Namespace)
+
+	rp := apis.RoutePrefix(Namespace)
+	hd := http.StripPrefix(rp, &apiServer{
+		ServeOptions: opts,
+		Logger:       klog.LoggerWithName(klog.Background(), Namespace),
+	})
+
+	mux.Handle(rp, hd)
+
+	return nil
+}
+
+type apiServer struct {
+	server.ServeOptions
+
+	Logger klog.Logger
+}
+
+func (s *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c := http.StatusMethodNotAllowed
+		http.Error(w, http.StatusText(c), c)
+
+		return
+	}
+
+	var o TokenOptions
+
+	// Authorization: Bearer {token} or Basic {roleID:secretID}.
+	{
+		var found bool
+
+		o.RoleID, o.SecretID, found = r.BasicAuth()
+		if found {
+			o.AuthMethod = AuthMethodAppRole
+		} else {
+			o.Token, found = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			o.AuthMethod = AuthMethodToken
+		}
+
+		if !found {
+			c := http.StatusUnauthorized
+			http.Error(w, http.StatusText(c), c)
+
+			return
+		}
+	}
+
+	// Path: {engine}/{mount}/{role},
+	// the nested mount path is escaped, e.g. kubernetes/kv%2Fteam/role.
+	{
+		paths := strings.SplitN(r.URL.EscapedPath(), "/", 3)
+		if len(paths) < 3 {
+			c := http.StatusBadRequest
+			http.Error(w, http.StatusText(c), c)
+
+			return
+		}
+
+		for i, p := range []*string{&o.Engine, &o.Mount, &o.Role} {
+			var err error
+
+			*p, err = url.PathUnescape(paths[i])
+			if err != nil {
+				c := http.StatusBadRequest
+				http.Error(w, http.StatusText(c), c)
+
+				return
+			}
+		}
+	}
+
+	// Query: [kubernetesNamespace=...&commonName=...&ttl=...&authMount=...&vaultNamespace=...].
+	{
+		q := r.URL.Query()
+
+		o.KubernetesNamespace = q.Get("kubernetesNamespace")
+		o.CommonName = q.Get("commonName")
+		o.AuthMount = q.Get("authMount")
+		o.VaultNamespace = q.Get("vaultNamespace")
+
+		if v := q.Get("ttl"); v != "" {
+			var err error
+
+			o.TTL, err = time.ParseDuration(v)
+			if err != nil {
+				c := http.StatusBadRequest
+				http.Error(w, http.StatusText(c), c)
+
+				return
+			}
+		}
+	}
+
+	if !s.Authorizer.Authorize(w, r, server.Attributes{
+		Provider: Namespace,
+		Cluster:  o.Engine + "/" + o.Mount,
+		Tenant:   o.VaultNamespace,
+		Role:     o.Role,
+	}) {
+		return
+	}
+
+	if s.RefuseEnv(w, o.Token, o.RoleID, o.SecretID) {
+		return
+	}
+
+	// Validate ahead, so that the tracked and logged key is the one of the cached token.
+	if err := o.Validate(); err != nil {
+		http.Error(w, "invalid options: "+err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	key, err := o.Key()
+	if err != nil {
+		s.Logger.Error(err, "error keying token")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	tk, err := GetToken(r.Context(), o, s.Cache)
+	if err != nil {
+		server.ErrorToken(w, s.Logger, err)
+
+		return
+	}
+
+	s.Refresher.Track(key, tk, func(ctx context.Context) (*token.Token, error) {
+		return RefreshToken(ctx, o, s.Cache)
+	})
+
+	var bs []byte
+	if r.Header.Get("X-KubeCIA-DeCapsuled") == "true" {
+		bs, err = tk.MarshalJSON()
+	} else {
+		bs, err = tk.ToKubeClientExecCredentialJSON()
+	}
+
+	if err != nil {
+		s.Logger.Error(err, "error marshaling token")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if tk.Stale() {
+		w.Header().Set("X-KubeCIA-Stale", "true")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	_, err = w.Write(bs)
+	if err != nil {
+		s.Logger.Error(err, "error writing response")
+		return
+	}
+
+	server.LogIssued(s.Logger, r, key, tk)
+}
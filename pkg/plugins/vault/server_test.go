@@ -0,0 +1,64 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/seal-io/kubecia/pkg/apis"
+	"github.com/seal-io/kubecia/pkg/apis/server"
+	"github.com/seal-io/kubecia/pkg/cache"
+)
+
+func TestServer_nestedMount(t *testing.T) {
+	v := newFakeVault(t)
+
+	// The central service requests its own Vault.
+	t.Setenv("VAULT_ADDR", v.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	mux := http.NewServeMux()
+	if err := Serve(ctx, mux, server.ServeOptions{Cache: cache.NewNone()}); err != nil {
+		t.Fatalf("error serving: %v", err)
+	}
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	cli := &Client{
+		Socket:         srv.URL,
+		Cache:          "none://",
+		VaultNamespace: "team",
+		AuthMethod:     AuthMethodToken,
+		Token:          "root",
+		Engine:         EnginePKI,
+		Mount:          "pki/int",
+		Role:           "client",
+		CommonName:     "alice",
+	}
+
+	tk, err := cli.GetTokenByHTTP(ctx, apis.Client(cli.Socket))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tk.ClientCertificateData != "CERT" || v.issues != 1 {
+		t.Errorf("expected issuing by the nested mount, got %q with %d issues", tk.ClientCertificateData, v.issues)
+	}
+
+	// Refuse the specified Vault instead of replacing it with the one of the central service.
+	cli.Address = "https://vault.example.com"
+
+	_, err = cli.GetToken(ctx)
+	if err == nil || !strings.Contains(err.Error(), "--address") {
+		t.Errorf("expected error of the unsupported address, got %v", err)
+	}
+
+	if v.issues != 1 {
+		t.Errorf("expected no issuing with the unsupported address, got %d issues", v.issues)
+	}
+}
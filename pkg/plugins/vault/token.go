@@ -0,0 +1,557 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/bytespool"
+	"github.com/seal-io/kubecia/pkg/cache"
+	"github.com/seal-io/kubecia/pkg/json"
+	"github.com/seal-io/kubecia/pkg/token"
+	"github.com/seal-io/kubecia/pkg/version"
+)
+
+const (
+	// DefaultAddress is the default address of Vault,
+	// it is overridden by $VAULT_ADDR.
+	DefaultAddress = "https://127.0.0.1:8200"
+
+	// DefaultKubernetesJWTPath is the default path of the service account token,
+	// which is used to log in with the Kubernetes auth method.
+	DefaultKubernetesJWTPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+const (
+	AuthMethodToken      = "token"
+	AuthMethodAppRole    = "approle"
+	AuthMethodKubernetes = "kubernetes"
+)
+
+const (
+	EngineKubernetes = "kubernetes"
+	EnginePKI        = "pki"
+)
+
+type TokenOptions struct {
+	// Address is the address of Vault,
+	// default is $VAULT_ADDR or DefaultAddress.
+	Address string
+	// VaultNamespace is the Vault Enterprise namespace.
+	VaultNamespace string
+
+	// AuthMethod is the method to log in Vault,
+	// select from token, approle and kubernetes.
+	AuthMethod string
+	// AuthMount is the mount path of the auth method,
+	// default is the same as AuthMethod.
+	AuthMount string
+	// Token is used by the token auth method.
+	Token string
+	// RoleID and SecretID are used by the approle auth method.
+	RoleID   string
+	SecretID string
+	// AuthRole and JWTPath are used by the kubernetes auth method.
+	AuthRole string
+	JWTPath  string
+
+	// Engine is the secrets engine to issue the credential,
+	// select from kubernetes and pki.
+	Engine string
+	// Mount is the mount path of the secrets engine,
+	// default is the same as Engine.
+	Mount string
+	// Role is the role of the secrets engine.
+	Role string
+	// KubernetesNamespace is the namespace to generate the service account token,
+	// only works with the kubernetes secrets engine.
+	KubernetesNamespace string
+	// CommonName is the common name of the client certificate,
+	// only works with the pki secrets engine.
+	CommonName string
+	// TTL is the requested lifetime of the credential,
+	// the role's default TTL is used if zero.
+	TTL time.Duration
+}
+
+func (o *TokenOptions) Validate() error {
+	var requiredTenant bool
+
+	if o.Address == "" {
+		o.Address = os.Getenv("VAULT_ADDR")
+		if o.Address == "" {
+			o.Address = DefaultAddress
+		}
+	}
+
+	if u, err := url.Parse(o.Address); err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New("address must be an absolute URL")
+	}
+
+	switch o.AuthMethod {
+	case AuthMethodToken:
+		if strings.HasPrefix(o.Token, "$") {
+			o.Token = os.ExpandEnv(o.Token)
+			requiredTenant = true
+		}
+
+		if o.Token == "" {
+			if requiredTenant {
+				return errors.New("hosted token is required")
+			}
+
+			return errors.New("token is required")
+		}
+	case AuthMethodAppRole:
+		if strings.HasPrefix(o.RoleID, "$") {
+			o.RoleID = os.ExpandEnv(o.RoleID)
+			requiredTenant = true
+		}
+
+		if o.RoleID == "" {
+			if requiredTenant {
+				return errors.New("hosted role ID is required")
+			}
+
+			return errors.New("role ID is required")
+		}
+
+		if strings.HasPrefix(o.SecretID, "$") {
+			o.SecretID = os.ExpandEnv(o.SecretID)
+			requiredTenant = true
+		}
+
+		if o.SecretID == "" {
+			if requiredTenant {
+				return errors.New("hosted secret ID is required")
+			}
+
+			return errors.New("secret ID is required")
+		}
+	case AuthMethodKubernetes:
+		if o.AuthRole == "" {
+			return errors.New("auth role is required")
+		}
+
+		if o.JWTPath == "" {
+			o.JWTPath = DefaultKubernetesJWTPath
+		}
+	default:
+		return fmt.Errorf("unknown auth method %q", o.AuthMethod)
+	}
+
+	if o.AuthMount == "" {
+		o.AuthMount = o.AuthMethod
+	}
+
+	switch o.Engine {
+	case EngineKubernetes:
+		if o.KubernetesNamespace == "" {
+			return errors.New("kubernetes namespace is required")
+		}
+	case EnginePKI:
+		if o.CommonName == "" {
+			return errors.New("common name is required")
+		}
+	default:
+		return fmt.Errorf("unknown secrets engine %q", o.Engine)
+	}
+
+	if o.Mount == "" {
+		o.Mount = o.Engine
+	}
+
+	if o.Role == "" {
+		return errors.New("role is required")
+	}
+
+	if o.TTL < 0 {
+		return errors.New("invalid TTL: negative")
+	}
+
+	return nil
+}
+
+func (o *TokenOptions) Key() (string, error) {
+	// Bind to the full login credential set,
+	// never index by the raw token.
+	d, err := cache.Digest(
+		Namespace,
+		o.Address, o.VaultNamespace,
+		o.AuthMethod, o.AuthMount, o.Token, o.RoleID, o.SecretID, o.AuthRole, o.JWTPath,
+		o.Engine, o.Mount, o.Role, o.KubernetesNamespace, o.CommonName, o.TTL.String(),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	ss := []string{
+		Namespace,
+		o.Engine,
+		strings.ReplaceAll(o.Mount, "/", "-"),
+		o.Role,
+		d,
+	}
+
+	return strings.Join(ss, "_"), nil
+}
+
+// Describe returns the description of the token requested with the options.
+func (o *TokenOptions) Describe() token.Description {
+	return token.Description{
+		Identity: o.Role,
+		Cluster:  o.Engine + "/" + o.Mount,
+	}
+}
+
+// GetToken retrieves a token from cache or remote.
+func GetToken(ctx context.Context, opts TokenOptions, cacher cache.Cache) (*token.Token, error) {
+	logger := klog.LoggerWithName(klog.Background(), Namespace)
+
+	err := opts.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+
+	ctx = token.WithDescription(ctx, opts.Describe())
+
+	key, err := opts.Key()
+	if err != nil {
+		return nil, fmt.Errorf("error keying token: %w", err)
+	}
+
+	return token.GetCached(ctx, logger, cacher, key, fetchToken(opts))
+}
+
+// RefreshToken requests a token from remote and saves it into cache,
+// ignores the cached one.
+func RefreshToken(ctx context.Context, opts TokenOptions, cacher cache.Cache) (*token.Token, error) {
+	logger := klog.LoggerWithName(klog.Background(), Namespace)
+
+	err := opts.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+
+	ctx = token.WithDescription(ctx, opts.Describe())
+
+	key, err := opts.Key()
+	if err != nil {
+		return nil, fmt.Errorf("error keying token: %w", err)
+	}
+
+	return token.Refresh(ctx, logger, cacher, key, fetchToken(opts))
+}
+
+func fetchToken(opts TokenOptions) token.FetchFunc {
+	return func(ctx context.Context) (*token.Token, error) {
+		tk, err := getToken(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error getting vault credential: %w", err)
+		}
+
+		return tk, nil
+	}
+}
+
+const requestTimeout = 30 * time.Second
+
+type (
+	// response is the common response of Vault API.
+	response struct {
+		LeaseDuration int64           `json:"lease_duration"`
+		Data          json.RawMessage `json:"data"`
+		Auth          *struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int64  `json:"lease_duration"`
+		} `json:"auth"`
+		Errors []string `json:"errors"`
+	}
+
+	// kubernetesCredential is the data of
+	// https://developer.hashicorp.com/vault/api-docs/secret/kubernetes#generate-credentials.
+	kubernetesCredential struct {
+		ServiceAccountToken string `json:"service_account_token"`
+	}
+
+	// pkiCredential is the data of
+	// https://developer.hashicorp.com/vault/api-docs/secret/pki#generate-certificate-and-key.
+	pkiCredential struct {
+		Certificate string   `json:"certificate"`
+		CAChain     []string `json:"ca_chain"`
+		PrivateKey  string   `json:"private_key"`
+		Expiration  int64    `json:"expiration"`
+	}
+)
+
+// getToken returns the token by logging in Vault and issuing the credential from the secrets engine.
+func getToken(ctx context.Context, opts TokenOptions) (*token.Token, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	body := map[string]any{}
+	if opts.TTL > 0 {
+		body["ttl"] = opts.TTL.String()
+	}
+
+	var path string
+
+	switch opts.Engine {
+	case EngineKubernetes:
+		path = opts.Mount + "/creds/" + opts.Role
+		body["kubernetes_namespace"] = opts.KubernetesNamespace
+	case EnginePKI:
+		path = opts.Mount + "/issue/" + opts.Role
+		body["common_name"] = opts.CommonName
+	}
+
+	resp, err := issue(ctx, opts, path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts.Engine {
+	case EnginePKI:
+		var cred pkiCredential
+		if err = json.Unmarshal(resp.Data, &cred); err != nil {
+			return nil, fmt.Errorf("error unmarshalling pki credential: %w", err)
+		}
+
+		if cred.Certificate == "" || cred.PrivateKey == "" {
+			return nil, errors.New("no certificate found")
+		}
+
+		tk := &token.Token{
+			Expiration:            time.Unix(cred.Expiration, 0),
+			ClientCertificateData: cred.Certificate,
+			ClientKeyData:         cred.PrivateKey,
+		}
+
+		return tk, nil
+	default:
+		var cred kubernetesCredential
+		if err = json.Unmarshal(resp.Data, &cred); err != nil {
+			return nil, fmt.Errorf("error unmarshalling kubernetes credential: %w", err)
+		}
+
+		if cred.ServiceAccountToken == "" {
+			return nil, errors.New("no token found")
+		}
+
+		tk := &token.Token{
+			Expiration: time.Now().Local().Add(time.Duration(resp.LeaseDuration) * time.Second),
+			Value:      cred.ServiceAccountToken,
+		}
+
+		return tk, nil
+	}
+}
+
+// issue logs in Vault and posts the given body to the given path of the secrets engine,
+// the logged in token is reused, and logs in again if the reused one is rejected.
+func issue(ctx context.Context, opts TokenOptions, path string, body any) (*response, error) {
+	vt, reused, err := login(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error logging in: %w", err)
+	}
+
+	resp, err := call(ctx, opts, vt, path, body)
+	if err != nil && reused && errors.Is(err, errPermissionDenied) {
+		logins.forget(opts)
+
+		vt, _, err = login(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error logging in: %w", err)
+		}
+
+		resp, err = call(ctx, opts, vt, path, body)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error issuing credential: %w", err)
+	}
+
+	return resp, nil
+}
+
+// logins holds the Vault tokens logged in with the approle and kubernetes auth methods,
+// the tokens are reused instead of revoked,
+// as revoking a token also revokes the leases of the credentials issued by it.
+var logins = loginCache{m: map[string]loginToken{}}
+
+type (
+	loginCache struct {
+		mu sync.Mutex
+		m  map[string]loginToken
+	}
+
+	loginToken struct {
+		token string
+		// expiresAt is zero if the token never expires.
+		expiresAt time.Time
+	}
+)
+
+// loginKey returns the key of the login credential of the given options,
+// the login cache never leaves the process, so the credential set is joined as is.
+func loginKey(opts TokenOptions) string {
+	return strings.Join([]string{
+		opts.Address, opts.VaultNamespace,
+		opts.AuthMethod, opts.AuthMount, opts.RoleID, opts.SecretID, opts.AuthRole, opts.JWTPath,
+	}, "\x00")
+}
+
+func (c *loginCache) get(opts TokenOptions) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lt, ok := c.m[loginKey(opts)]
+	if !ok || (!lt.expiresAt.IsZero() && time.Now().After(lt.expiresAt)) {
+		return "", false
+	}
+
+	return lt.token, true
+}
+
+func (c *loginCache) set(opts TokenOptions, vt string, leaseSeconds int64) {
+	lt := loginToken{token: vt}
+	if leaseSeconds > 0 {
+		// Stop reusing before the token expires in the middle of issuing.
+		lt.expiresAt = time.Now().Add(time.Duration(leaseSeconds) * time.Second * 4 / 5)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, v := range c.m {
+		if !v.expiresAt.IsZero() && now.After(v.expiresAt) {
+			delete(c.m, k)
+		}
+	}
+
+	c.m[loginKey(opts)] = lt
+}
+
+func (c *loginCache) forget(opts TokenOptions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.m, loginKey(opts))
+}
+
+// login returns the Vault token of the given options,
+// and whether the token is reused.
+func login(ctx context.Context, opts TokenOptions) (string, bool, error) {
+	body := map[string]any{}
+
+	switch opts.AuthMethod {
+	default:
+		return opts.Token, false, nil
+	case AuthMethodAppRole:
+		body["role_id"] = opts.RoleID
+		body["secret_id"] = opts.SecretID
+	case AuthMethodKubernetes:
+		jwt, err := os.ReadFile(opts.JWTPath)
+		if err != nil {
+			return "", false, fmt.Errorf("error reading service account token: %w", err)
+		}
+
+		body["role"] = opts.AuthRole
+		body["jwt"] = strings.TrimSpace(string(jwt))
+	}
+
+	if vt, ok := logins.get(opts); ok {
+		return vt, true, nil
+	}
+
+	resp, err := call(ctx, opts, "", "auth/"+opts.AuthMount+"/login", body)
+	if err != nil {
+		return "", false, err
+	}
+
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return "", false, errors.New("no client token found")
+	}
+
+	logins.set(opts, resp.Auth.ClientToken, resp.Auth.LeaseDuration)
+
+	return resp.Auth.ClientToken, false, nil
+}
+
+// errPermissionDenied is returned if Vault rejects the token,
+// e.g. the token is expired or revoked.
+var errPermissionDenied = errors.New("permission denied")
+
+// call posts the given body to the given path of Vault API.
+func call(ctx context.Context, opts TokenOptions, vt, path string, body any) (*response, error) {
+	u, err := url.JoinPath(opts.Address, "v1", path)
+	if err != nil {
+		return nil, fmt.Errorf("error building request url: %w", err)
+	}
+
+	bs, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(bs))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.Get())
+
+	if vt != "" {
+		req.Header.Set("X-Vault-Token", vt)
+	}
+
+	if opts.VaultNamespace != "" {
+		req.Header.Set("X-Vault-Namespace", opts.VaultNamespace)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	buf := bytespool.GetBuffer()
+	defer bytespool.Put(buf)
+
+	_, err = io.Copy(buf, resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error copying response body: %w", err)
+	}
+
+	var r response
+	if err = json.Unmarshal(buf.Bytes(), &r); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("error unmarshalling response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusForbidden && vt != "" {
+			return nil, fmt.Errorf("error response: %s: %w", resp.Status, errPermissionDenied)
+		}
+
+		if len(r.Errors) != 0 {
+			return nil, fmt.Errorf("error response: %s: %s", resp.Status, strings.Join(r.Errors, "; "))
+		}
+
+		return nil, fmt.Errorf("error response: %s", resp.Status)
+	}
+
+	return &r, nil
+}
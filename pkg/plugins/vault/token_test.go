@@ -0,0 +1,261 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seal-io/kubecia/pkg/cache"
+	"github.com/seal-io/kubecia/pkg/json"
+)
+
+// fakeVault is a Vault stand-in,
+// which serves the approle auth method, the kubernetes and pki secrets engines,
+// the pki secrets engine is mounted at both pki and the nested pki/int.
+type fakeVault struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	tokens map[string]bool
+	logins int
+	issues int
+}
+
+func newFakeVault(t *testing.T) *fakeVault {
+	t.Helper()
+
+	v := &fakeVault{tokens: map[string]bool{"root": true}}
+	v.Server = httptest.NewServer(http.HandlerFunc(v.serve))
+	t.Cleanup(v.Close)
+
+	return v
+}
+
+// revokeAll revokes all logged in tokens.
+func (v *fakeVault) revokeAll() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.tokens = map[string]bool{"root": true}
+}
+
+func (v *fakeVault) serve(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var body map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&body)
+
+	reply := func(code int, v any) {
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(v)
+	}
+
+	if r.Header.Get("X-Vault-Namespace") != "team" {
+		reply(http.StatusBadRequest, map[string]any{"errors": []string{"missing namespace"}})
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v1/auth/approle/login":
+		if body["role_id"] != "role" || body["secret_id"] != "secret" {
+			reply(http.StatusBadRequest, map[string]any{"errors": []string{"invalid role or secret ID"}})
+			return
+		}
+
+		v.logins++
+		vt := "s.login-" + strconv.Itoa(v.logins)
+		v.tokens[vt] = true
+
+		reply(http.StatusOK, map[string]any{
+			"auth": map[string]any{"client_token": vt, "lease_duration": 3600},
+		})
+	case "/v1/kubernetes/creds/deployer", "/v1/pki/issue/client", "/v1/pki/int/issue/client":
+		if !v.tokens[r.Header.Get("X-Vault-Token")] {
+			reply(http.StatusForbidden, map[string]any{"errors": []string{"permission denied"}})
+			return
+		}
+
+		v.issues++
+
+		if strings.HasSuffix(r.URL.Path, "/issue/client") {
+			reply(http.StatusOK, map[string]any{
+				"data": map[string]any{
+					"certificate": "CERT",
+					"private_key": "KEY",
+					"expiration":  time.Now().Add(time.Hour).Unix(),
+				},
+			})
+
+			return
+		}
+
+		if body["kubernetes_namespace"] != "ci" || body["ttl"] != "20m0s" {
+			reply(http.StatusBadRequest, map[string]any{"errors": []string{"unexpected request"}})
+			return
+		}
+
+		reply(http.StatusOK, map[string]any{
+			"lease_duration": 1200,
+			"data":           map[string]any{"service_account_token": "sa-" + strconv.Itoa(v.issues)},
+		})
+	default:
+		reply(http.StatusNotFound, map[string]any{"errors": []string{"not found"}})
+	}
+}
+
+func TestGetToken_appRole(t *testing.T) {
+	var (
+		ctx = context.Background()
+		v   = newFakeVault(t)
+	)
+
+	c, err := cache.Open(ctx, "memory://")
+	if err != nil {
+		t.Fatalf("error opening cache: %v", err)
+	}
+
+	t.Cleanup(func() { _ = c.Close() })
+
+	o := TokenOptions{
+		Address:             v.URL,
+		VaultNamespace:      "team",
+		AuthMethod:          AuthMethodAppRole,
+		RoleID:              "role",
+		SecretID:            "secret",
+		Engine:              EngineKubernetes,
+		Role:                "deployer",
+		KubernetesNamespace: "ci",
+		TTL:                 20 * time.Minute,
+	}
+
+	tk, err := GetToken(ctx, o, c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tk.Value != "sa-1" {
+		t.Errorf("expected sa-1, got %q", tk.Value)
+	}
+
+	if d := time.Until(tk.Expiration); d < 19*time.Minute || d > 20*time.Minute {
+		t.Errorf("expected expiration of the lease duration, got %s", d)
+	}
+
+	// Reuse the logged in token.
+	tk, err = RefreshToken(ctx, o, c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tk.Value != "sa-2" || v.logins != 1 {
+		t.Errorf("expected sa-2 with 1 login, got %q with %d logins", tk.Value, v.logins)
+	}
+
+	// Log in again once the reused token is rejected.
+	v.revokeAll()
+
+	tk, err = RefreshToken(ctx, o, c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tk.Value != "sa-3" || v.logins != 2 {
+		t.Errorf("expected sa-3 with 2 logins, got %q with %d logins", tk.Value, v.logins)
+	}
+
+	// Reject the wrong secret ID.
+	o.SecretID = "wrong"
+
+	_, err = RefreshToken(ctx, o, c)
+	if err == nil {
+		t.Error("expected error of the wrong secret ID, got nil")
+	}
+}
+
+func TestGetToken_pki(t *testing.T) {
+	var (
+		ctx = context.Background()
+		v   = newFakeVault(t)
+	)
+
+	c, err := cache.Open(ctx, "none://")
+	if err != nil {
+		t.Fatalf("error opening cache: %v", err)
+	}
+
+	o := TokenOptions{
+		Address:        v.URL,
+		VaultNamespace: "team",
+		AuthMethod:     AuthMethodToken,
+		Token:          "root",
+		Engine:         EnginePKI,
+		Role:           "client",
+		CommonName:     "alice",
+	}
+
+	tk, err := GetToken(ctx, o, c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tk.ClientCertificateData != "CERT" || tk.ClientKeyData != "KEY" {
+		t.Errorf("unexpected client certificate: %q, %q", tk.ClientCertificateData, tk.ClientKeyData)
+	}
+
+	if v.logins != 0 {
+		t.Errorf("expected no login with the token auth method, got %d", v.logins)
+	}
+
+	// Never retry the rejected static token.
+	o.Token = "invalid"
+
+	_, err = GetToken(ctx, o, c)
+	if err == nil {
+		t.Error("expected error of the invalid token, got nil")
+	}
+}
+
+func TestTokenOptions_Key(t *testing.T) {
+	base := TokenOptions{
+		AuthMethod:          AuthMethodAppRole,
+		RoleID:              "role",
+		SecretID:            "secret",
+		Engine:              EngineKubernetes,
+		Role:                "deployer",
+		KubernetesNamespace: "ci",
+	}
+
+	if err := base.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bk, err := base.Key()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	changes := map[string]func(o *TokenOptions){
+		"secret ID":            func(o *TokenOptions) { o.SecretID = "other" },
+		"role ID":              func(o *TokenOptions) { o.RoleID = "other" },
+		"kubernetes namespace": func(o *TokenOptions) { o.KubernetesNamespace = "other" },
+		"vault namespace":      func(o *TokenOptions) { o.VaultNamespace = "other" },
+		"ttl":                  func(o *TokenOptions) { o.TTL = time.Hour },
+	}
+
+	for name, change := range changes {
+		t.Run(name, func(t *testing.T) {
+			o := base
+			change(&o)
+
+			if k, _ := o.Key(); k == bk {
+				t.Errorf("expected different key after changing %s", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/cache"
+)
+
+const (
+	// BackoffKeySuffix is the suffix of the cache key to record the fetching failures,
+	// the negative entry is saved along with the token entry.
+	BackoffKeySuffix = ".backoff"
+
+	backoffInitial = 1 * time.Second
+	backoffMax     = 5 * time.Minute
+	backoffFactor  = 2
+	backoffJitter  = 0.2
+)
+
+// BackoffError is returned if fetching a token failed,
+// the following fetches of the same key are suppressed until RetryAt.
+type BackoffError struct {
+	// Failures is the count of consecutive failures.
+	Failures int `json:"failures"`
+	// Message is the message of the last failure.
+	Message string `json:"message"`
+	// RetryAt is the time to allow the next fetch.
+	RetryAt time.Time `json:"retryAt"`
+	// Cached indicates the error is replied from cache without fetching.
+	Cached bool `json:"-"`
+
+	err error
+}
+
+func (e *BackoffError) Error() string {
+	return fmt.Sprintf("backing off after %d failure(s), retry after %s: %s",
+		e.Failures, e.RetryAfter().Round(time.Second), e.Message)
+}
+
+func (e *BackoffError) Unwrap() error {
+	return e.err
+}
+
+// RetryAfter returns the duration until the next fetch is allowed.
+func (e *BackoffError) RetryAfter() time.Duration {
+	d := time.Until(e.RetryAt)
+	if d < 0 {
+		return 0
+	}
+
+	return d
+}
+
+// getBackoff returns the recorded BackoffError of the given key,
+// which is Cached if the backoff is not over yet,
+// returns nil if not found.
+func getBackoff(ctx context.Context, logger klog.Logger, cacher cache.Cache, key string) *BackoffError {
+	if cacher == nil {
+		return nil
+	}
+
+	var be *BackoffError
+
+	bs, err := cacher.Get(ctx, key+BackoffKeySuffix)
+	if err != nil {
+		if !errors.Is(err, cache.ErrEntryNotFound) {
+			logger.Error(err, "error retrieving backoff from cache")
+		}
+
+		return nil
+	}
+
+	env, err := DecodeEnvelope(key+BackoffKeySuffix, bs)
+	if err == nil {
+		be, err = env.Backoff()
+	}
+
+	if err != nil {
+		logger.Error(err, "error decoding cached backoff")
+		return nil
+	}
+
+	be.Cached = time.Now().Before(be.RetryAt)
+
+	return be
+}
+
+// setBackoff records the failure of the given key,
+// and returns the BackoffError wrapping the given error.
+func setBackoff(
+	ctx context.Context,
+	logger klog.Logger,
+	cacher cache.Cache,
+	key string,
+	prev *BackoffError,
+	err error,
+) error {
+	be := &BackoffError{
+		Failures: 1,
+		Message:  err.Error(),
+		err:      err,
+	}
+
+	if prev != nil {
+		be.Failures = prev.Failures + 1
+	}
+
+	delay := backoffInitial
+	for i := 1; i < be.Failures && delay < backoffMax; i++ {
+		delay *= backoffFactor
+	}
+
+	if delay > backoffMax {
+		delay = backoffMax
+	}
+
+	// Spread the retries of the clients sharing the same credential.
+	delay += time.Duration((rand.Float64()*2 - 1) * backoffJitter * float64(delay)) //nolint:gosec
+
+	be.RetryAt = time.Now().Add(delay)
+
+	if cacher != nil {
+		// Keep the failure count for a while after the backoff,
+		// so that the next failure backs off longer.
+		var bs []byte
+
+		env := NewBackoffEnvelope(key+BackoffKeySuffix, be)
+		env.Describe(descriptionFrom(ctx))
+
+		bs, err = env.MarshalBinary()
+		if err == nil {
+			err = cacher.SetWithTTL(ctx, key+BackoffKeySuffix, bs, delay+backoffMax)
+		}
+
+		if err != nil {
+			logger.Error(err, "error saving backoff to cache")
+		}
+	}
+
+	return be
+}
+
+// resetBackoff removes the failure record of the given key.
+func resetBackoff(ctx context.Context, logger klog.Logger, cacher cache.Cache, key string) {
+	if cacher == nil {
+		return
+	}
+
+	_, err := cacher.Delete(ctx, key+BackoffKeySuffix)
+	if err != nil && !errors.Is(err, cache.ErrEntryNotFound) {
+		logger.Error(err, "error removing backoff from cache")
+	}
+}
@@ -0,0 +1,266 @@
+package token
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/cache"
+)
+
+// FetchFunc requests a new token from remote.
+type FetchFunc = func(ctx context.Context) (*Token, error)
+
+// flights collapses the concurrent fetches of the same key.
+var flights singleflight.Group
+
+type verifierContextKey struct{}
+
+// WithVerifier returns a context carrying the verifier of the given secret,
+// the token saved with the context is only returned to the context carrying the same verifier.
+func WithVerifier(ctx context.Context, secret string) context.Context {
+	return context.WithValue(ctx, verifierContextKey{}, secret)
+}
+
+// verifierFrom returns the verifier carried by the given context, blank if not found.
+func verifierFrom(ctx context.Context) (string, error) {
+	s, ok := ctx.Value(verifierContextKey{}).(string)
+	if !ok {
+		return "", nil
+	}
+
+	return cache.Digest("verifier", s)
+}
+
+// Description describes the requester of a token,
+// which is saved along with the token for listing the cache.
+type Description struct {
+	// Identity is the identity requested the token, e.g. the access key ID.
+	Identity string
+	// Cluster is the cluster served by the token, e.g. the region and cluster name.
+	Cluster string
+}
+
+type descriptionContextKey struct{}
+
+// WithDescription returns a context carrying the given description,
+// which is saved into the header of the cache entry created with the context.
+func WithDescription(ctx context.Context, d Description) context.Context {
+	return context.WithValue(ctx, descriptionContextKey{}, d)
+}
+
+func descriptionFrom(ctx context.Context) Description {
+	d, _ := ctx.Value(descriptionContextKey{}).(Description)
+	return d
+}
+
+// GetCached retrieves the token of the given key from cache,
+// or fetches a new one and saves it into cache if not found or soft expired.
+//
+// If fetching failed, the cached token is returned as stale if it has not expired yet.
+func GetCached(
+	ctx context.Context,
+	logger klog.Logger,
+	cacher cache.Cache,
+	key string,
+	fetch FetchFunc,
+) (*Token, error) {
+	if tk := getFromCache(ctx, logger, cacher, key); tk != nil && !tk.SoftExpired() {
+		return tk, nil
+	}
+
+	return doFlight(ctx, key, func(ctx context.Context) (*Token, error) {
+		unlock := lock(ctx, logger, cacher, key)
+		defer unlock()
+
+		// Another flight or process may have saved the token just now.
+		stk := getFromCache(ctx, logger, cacher, key)
+		if stk != nil && !stk.SoftExpired() {
+			return stk, nil
+		}
+
+		tk, err := refresh(ctx, logger, cacher, key, fetch)
+		if err != nil && stk != nil && !stk.Expired() {
+			// Do not flood the log during backoff.
+			if be := (*BackoffError)(nil); errors.As(err, &be) && be.Cached {
+				logger.V(4).Info("backing off, serving the stale token", "expiration", stk.expiration())
+			} else {
+				logger.Error(err, "error refreshing token, serving the stale one",
+					"expiration", stk.expiration())
+			}
+
+			stk.MarkStale()
+
+			return stk, nil
+		}
+
+		return tk, err
+	})
+}
+
+// Refresh fetches a new token and saves it into cache with the given key,
+// concurrent calls of the same key share one fetch.
+func Refresh(
+	ctx context.Context,
+	logger klog.Logger,
+	cacher cache.Cache,
+	key string,
+	fetch FetchFunc,
+) (*Token, error) {
+	return doFlight(ctx, key, func(ctx context.Context) (*Token, error) {
+		unlock := lock(ctx, logger, cacher, key)
+		defer unlock()
+
+		return refresh(ctx, logger, cacher, key, fetch)
+	})
+}
+
+// doFlight calls f once for all concurrent callers of the same key,
+// each caller receives a copy of the result.
+func doFlight(ctx context.Context, key string, f FetchFunc) (*Token, error) {
+	ch := flights.DoChan(key, func() (any, error) {
+		// Detach from the caller's cancellation,
+		// other callers are waiting for the result.
+		return f(context.WithoutCancel(ctx))
+	})
+
+	select {
+	case r := <-ch:
+		if r.Err != nil {
+			return nil, r.Err
+		}
+
+		tk := *r.Val.(*Token)
+
+		return &tk, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+const lockTimeout = 1 * time.Minute
+
+// lock holds the lock of the given key if the cache is shared across processes,
+// so that only one process fetches and others reuse its result.
+func lock(ctx context.Context, logger klog.Logger, cacher cache.Cache, key string) func() {
+	l, ok := cacher.(cache.Locker)
+	if !ok {
+		return func() {}
+	}
+
+	// Do not wait forever for a stuck holder.
+	ctx, cancel := context.WithTimeout(ctx, lockTimeout)
+	defer cancel()
+
+	unlock, err := l.Lock(ctx, key)
+	if err != nil {
+		logger.Error(err, "error locking cache, fetching without lock")
+		return func() {}
+	}
+
+	return unlock
+}
+
+func getFromCache(ctx context.Context, logger klog.Logger, cacher cache.Cache, key string) *Token {
+	if cacher == nil {
+		return nil
+	}
+
+	bs, err := cacher.Get(ctx, key)
+	if err != nil && !errors.Is(err, cache.ErrEntryNotFound) {
+		logger.Error(err, "error retrieving token from cache")
+	}
+
+	if len(bs) == 0 {
+		return nil
+	}
+
+	env, err := DecodeEnvelope(key, bs)
+	if err != nil {
+		logger.Error(err, "error decoding cached entry")
+		return nil
+	}
+
+	v, err := verifierFrom(ctx)
+	if err != nil {
+		logger.Error(err, "error digesting verifier")
+		return nil
+	}
+
+	if v != "" && subtle.ConstantTimeCompare([]byte(v), []byte(env.Verifier)) != 1 {
+		logger.Info("ignored cached token with mismatched secret")
+		return nil
+	}
+
+	tk, err := env.Token()
+	if err != nil {
+		logger.Error(err, "error decoding cached token")
+		return nil
+	}
+
+	if tk.Expired() {
+		return nil
+	}
+
+	return tk
+}
+
+func refresh(
+	ctx context.Context,
+	logger klog.Logger,
+	cacher cache.Cache,
+	key string,
+	fetch FetchFunc,
+) (*Token, error) {
+	// Reply the failure without requesting during backoff.
+	be := getBackoff(ctx, logger, cacher, key)
+	if be != nil && be.Cached {
+		return nil, be
+	}
+
+	// Request the token from remote.
+	tk, err := fetch(ctx)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+
+		return nil, setBackoff(ctx, logger, cacher, key, be, err)
+	}
+
+	if be != nil {
+		resetBackoff(ctx, logger, cacher, key)
+	}
+
+	// Save the token into cache.
+	if cacher != nil {
+		var bs []byte
+
+		env, err := NewTokenEnvelope(key, tk)
+		if err == nil {
+			env.Verifier, err = verifierFrom(ctx)
+		}
+
+		if err == nil {
+			env.Describe(descriptionFrom(ctx))
+			bs, err = env.MarshalBinary()
+		}
+
+		if err != nil {
+			logger.Error(err, "error marshaling requested token")
+		}
+
+		if len(bs) != 0 {
+			err = cacher.SetWithTTL(ctx, key, bs, tk.CacheTTL())
+			if err != nil {
+				logger.Error(err, "error saving token to cache")
+			}
+		}
+	}
+
+	return tk, nil
+}
@@ -0,0 +1,206 @@
+package token
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/cache"
+)
+
+func newMemoryCache(t *testing.T) cache.Cache {
+	t.Helper()
+
+	c, err := cache.NewMemory(context.Background())
+	if err != nil {
+		t.Fatalf("error creating cache: %v", err)
+	}
+
+	t.Cleanup(func() { _ = c.Close() })
+
+	return c
+}
+
+// newFetch returns a FetchFunc issuing the numbered tokens,
+// and the counter of the calls.
+func newFetch() (FetchFunc, *atomic.Int32) {
+	n := &atomic.Int32{}
+
+	return func(ctx context.Context) (*Token, error) {
+		return &Token{
+			Expiration: time.Now().Add(time.Hour),
+			Value:      "token-" + strconv.Itoa(int(n.Add(1))),
+		}, nil
+	}, n
+}
+
+func TestGetCached_verifier(t *testing.T) {
+	var (
+		logger   = klog.Background()
+		c        = newMemoryCache(t)
+		fetch, n = newFetch()
+		key      = "aws_id_region_cluster_self_digest"
+	)
+
+	cases := []struct {
+		name     string
+		ctx      context.Context
+		expected string
+	}{
+		{
+			name:     "save with the secret",
+			ctx:      WithVerifier(context.Background(), "secret"),
+			expected: "token-1",
+		},
+		{
+			name:     "hit with the same secret",
+			ctx:      WithVerifier(context.Background(), "secret"),
+			expected: "token-1",
+		},
+		{
+			name:     "miss with a wrong secret",
+			ctx:      WithVerifier(context.Background(), "wrong"),
+			expected: "token-2",
+		},
+		{
+			name:     "miss with the overwritten secret",
+			ctx:      WithVerifier(context.Background(), "secret"),
+			expected: "token-3",
+		},
+		{
+			name:     "hit without verifying",
+			ctx:      context.Background(),
+			expected: "token-3",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tk, err := GetCached(tc.ctx, logger, c, key, fetch)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tk.Value != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, tk.Value)
+			}
+		})
+	}
+
+	if n.Load() != 3 {
+		t.Errorf("expected 3 fetches, got %d", n.Load())
+	}
+}
+
+func TestGetCached_concurrent(t *testing.T) {
+	var (
+		logger = klog.Background()
+		c      = newMemoryCache(t)
+		n      atomic.Int32
+		start  = make(chan struct{})
+	)
+
+	// Block the fetch until all callers are waiting.
+	fetch := func(ctx context.Context) (*Token, error) {
+		n.Add(1)
+		<-start
+
+		return &Token{Expiration: time.Now().Add(time.Hour), Value: "token"}, nil
+	}
+
+	const callers = 32
+
+	var (
+		wg   sync.WaitGroup
+		tks  = make([]*Token, callers)
+		errs = make([]error, callers)
+	)
+
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			tks[i], errs[i] = GetCached(context.Background(), logger, c, "key", fetch)
+		}(i)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	close(start)
+	wg.Wait()
+
+	if n.Load() != 1 {
+		t.Errorf("expected 1 upstream call, got %d", n.Load())
+	}
+
+	for i := range tks {
+		if errs[i] != nil {
+			t.Fatalf("unexpected error: %v", errs[i])
+		}
+
+		if tks[i].Value != "token" {
+			t.Errorf("expected token, got %q", tks[i].Value)
+		}
+
+		// Each caller receives a copy.
+		if i > 0 && tks[i] == tks[0] {
+			t.Error("expected a copy of the token, got the shared one")
+		}
+	}
+}
+
+func TestGetCached_canceled(t *testing.T) {
+	var (
+		logger = klog.Background()
+		c      = newMemoryCache(t)
+		start  = make(chan struct{})
+	)
+
+	fetch := func(ctx context.Context) (*Token, error) {
+		<-start
+
+		// The fetch is detached from the canceled caller.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		return &Token{Expiration: time.Now().Add(time.Hour), Value: "token"}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan *Token)
+
+	go func() {
+		tk, _ := GetCached(context.Background(), logger, c, "key", fetch)
+		done <- tk
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	errc := make(chan error)
+
+	go func() {
+		_, err := GetCached(ctx, logger, c, "key", fetch)
+		errc <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	close(start)
+
+	if tk := <-done; tk == nil || tk.Value != "token" {
+		t.Errorf("expected token of the waiting caller, got %v", tk)
+	}
+}
@@ -0,0 +1,288 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"k8s.io/klog/v2"
+
+	"github.com/seal-io/kubecia/pkg/cache"
+)
+
+// ErrNotSupported is returned if the cache does not support the operation.
+var ErrNotSupported = errors.New("operation is not supported by the cache")
+
+type (
+	// Entry describes a cached token.
+	Entry struct {
+		// Key is the cache key.
+		Key string `json:"key"`
+		// Provider is the provider issued the token.
+		Provider string `json:"provider"`
+		// Identity is the identity requested the token, e.g. the access key ID.
+		Identity string `json:"identity,omitempty"`
+		// Cluster is the cluster served by the token, e.g. the region and cluster name.
+		Cluster string `json:"cluster,omitempty"`
+		// Size is the size of the cached entry.
+		Size int `json:"size"`
+		// CacheExpiration is the time to evict the entry from cache.
+		CacheExpiration time.Time `json:"cacheExpiration"`
+
+		// Metadata is the decoded metadata of the token,
+		// only presents at inspecting.
+		Metadata *EntryMetadata `json:"metadata,omitempty"`
+	}
+
+	// EntryMetadata holds the decoded metadata of a cached token,
+	// all secrets are redacted.
+	EntryMetadata struct {
+		// Version is the envelope version of the entry,
+		// zero if the entry is saved before the envelope introduced.
+		Version int `json:"version"`
+		// Kind is the kind of the entry, select from "token" and "backoff".
+		Kind string `json:"kind"`
+		// CreatedAt is the time the entry created, absent if unknown.
+		CreatedAt *time.Time `json:"createdAt,omitempty"`
+		// Expiration is the expiration of the token.
+		Expiration *time.Time `json:"expiration,omitempty"`
+		// Value is the redacted token.
+		Value string `json:"value,omitempty"`
+		// Claims is the unverified claims if the token is a JWT.
+		Claims map[string]any `json:"claims,omitempty"`
+		// ClientCertificateExpiration is the expiration of the client certificate if present.
+		ClientCertificateExpiration *time.Time `json:"clientCertificateExpiration,omitempty"`
+		// Backoff is the recorded failures if the entry is a negative one.
+		Backoff *BackoffError `json:"backoff,omitempty"`
+	}
+)
+
+// newEntry returns the Entry of the given key described by the given envelope header,
+// only the provider is known if the envelope is nil.
+func newEntry(key string, env *Envelope) Entry {
+	if env == nil {
+		return Entry{
+			Key:      key,
+			Provider: providerOf(key),
+		}
+	}
+
+	return Entry{
+		Key:      key,
+		Provider: env.Provider,
+		Identity: env.Identity,
+		Cluster:  env.Cluster,
+	}
+}
+
+// ListEntries returns the cached tokens whose key matches the given pattern,
+// a blank pattern matches all.
+//
+// The entries are described by their envelope headers,
+// rather than parsing the keys, which may contain the separator.
+func ListEntries(ctx context.Context, cacher cache.Cache, pattern string) ([]Entry, error) {
+	l, ok := cacher.(cache.Lister)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	if pattern == "" {
+		pattern = "*"
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	eis, err := l.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing cache: %w", err)
+	}
+
+	now := time.Now()
+	es := make([]Entry, 0, len(eis))
+
+	for i := range eis {
+		if ok, _ := path.Match(pattern, eis[i].Key); !ok {
+			continue
+		}
+
+		bs, err := cacher.Get(ctx, eis[i].Key)
+		if err != nil {
+			if errors.Is(err, cache.ErrEntryNotFound) {
+				continue
+			}
+
+			return nil, fmt.Errorf("error getting %s: %w", eis[i].Key, err)
+		}
+
+		// Describe the undecodable entry by its key only.
+		env, _ := DecodeEnvelope(eis[i].Key, bs)
+
+		e := newEntry(eis[i].Key, env)
+		e.Size = eis[i].Size
+		e.CacheExpiration = now.Add(eis[i].TTL).Round(time.Second)
+		es = append(es, e)
+	}
+
+	sort.Slice(es, func(i, j int) bool {
+		return es[i].Key < es[j].Key
+	})
+
+	return es, nil
+}
+
+// InspectEntry returns the cached token of the given key with decoded metadata.
+func InspectEntry(ctx context.Context, cacher cache.Cache, key string) (*Entry, error) {
+	var (
+		bs  []byte
+		ttl time.Duration
+		err error
+	)
+
+	if g, ok := cacher.(cache.TTLGetter); ok {
+		bs, ttl, err = g.GetWithTTL(ctx, key)
+	} else {
+		bs, err = cacher.Get(ctx, key)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := DecodeEnvelope(key, bs)
+	if err != nil {
+		return nil, err
+	}
+
+	e := newEntry(key, env)
+	e.Size = len(bs)
+
+	if ttl > 0 {
+		e.CacheExpiration = time.Now().Add(ttl).Round(time.Second)
+	}
+
+	e.Metadata = &EntryMetadata{
+		Version: env.Version,
+		Kind:    env.Kind,
+	}
+
+	if !env.CreatedAt.IsZero() {
+		e.Metadata.CreatedAt = &env.CreatedAt
+	}
+
+	if env.Kind == EnvelopeKindBackoff {
+		e.Metadata.Backoff, err = env.Backoff()
+		if err != nil {
+			return nil, err
+		}
+
+		return &e, nil
+	}
+
+	tk, err := env.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	e.Metadata.Value = redact(tk.Value)
+
+	if exp := tk.expiration(); !exp.IsZero() {
+		e.Metadata.Expiration = &exp
+	}
+
+	if exp := tk.ClientCertificateExpiration(); !exp.IsZero() {
+		e.Metadata.ClientCertificateExpiration = &exp
+	}
+
+	var claims jwt.MapClaims
+	if _, _, err = jwt.NewParser().ParseUnverified(tk.Value, &claims); err == nil {
+		e.Metadata.Claims = claims
+	}
+
+	return &e, nil
+}
+
+// RemoveEntries removes the cached tokens whose key matches the given pattern,
+// and returns the removed keys.
+func RemoveEntries(ctx context.Context, cacher cache.Cache, pattern string) ([]string, error) {
+	if pattern == "" {
+		return nil, errors.New("invalid pattern: blank")
+	}
+
+	// Remove the exact key without listing,
+	// along with its failure record.
+	if !strings.ContainsAny(pattern, `*?[\`) {
+		if !strings.HasSuffix(pattern, BackoffKeySuffix) {
+			resetBackoff(ctx, klog.Background(), cacher, pattern)
+		}
+
+		_, err := cacher.Delete(ctx, pattern)
+		if err != nil {
+			if errors.Is(err, cache.ErrEntryNotFound) {
+				return nil, nil
+			}
+
+			return nil, err
+		}
+
+		return []string{pattern}, nil
+	}
+
+	es, err := ListEntries(ctx, cacher, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := make([]string, 0, len(es))
+
+	for i := range es {
+		_, err = cacher.Delete(ctx, es[i].Key)
+		if err != nil && !errors.Is(err, cache.ErrEntryNotFound) {
+			return ks, fmt.Errorf("error removing %s: %w", es[i].Key, err)
+		}
+
+		ks = append(ks, es[i].Key)
+	}
+
+	return ks, nil
+}
+
+// PruneEntries removes the expired entries, and returns the removed count.
+func PruneEntries(ctx context.Context, cacher cache.Cache) (int, error) {
+	p, ok := cacher.(cache.Pruner)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+
+	return p.Prune(ctx)
+}
+
+// GetStats returns the statistics of the cache.
+func GetStats(ctx context.Context, cacher cache.Cache) (*cache.Stats, error) {
+	g, ok := cacher.(cache.StatsGetter)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	s, err := g.Stats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting stats: %w", err)
+	}
+
+	return &s, nil
+}
+
+// redact keeps the length of the given secret only.
+func redact(s string) string {
+	if s == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("REDACTED(%d)", len(s))
+}
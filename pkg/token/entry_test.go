@@ -0,0 +1,108 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+func TestListEntries(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		logger   = klog.Background()
+		c        = newMemoryCache(t)
+		fetch, _ = newFetch()
+	)
+
+	// The names contain the separator of the key segments.
+	d := Description{
+		Identity: "AKIA_ID",
+		Cluster:  "us-east-1/prod_blue",
+	}
+	key := "aws_AKIA_ID_us-east-1_prod_blue_self_digest"
+
+	_, err := GetCached(WithDescription(ctx, d), logger, c, key, fetch)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Save a legacy entry without description.
+	var tk *Token
+
+	tk, err = fetch(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bs, err := tk.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = c.SetWithTTL(ctx, "gcp_legacy_digest", bs, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	es, err := ListEntries(ctx, c, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(es) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(es))
+	}
+
+	expected := []Entry{
+		{Key: key, Provider: "aws", Identity: d.Identity, Cluster: d.Cluster},
+		{Key: "gcp_legacy_digest", Provider: "gcp"},
+	}
+
+	for i := range expected {
+		if es[i].Key != expected[i].Key ||
+			es[i].Provider != expected[i].Provider ||
+			es[i].Identity != expected[i].Identity ||
+			es[i].Cluster != expected[i].Cluster {
+			t.Errorf("expected %+v, got %+v", expected[i], es[i])
+		}
+	}
+
+	e, err := InspectEntry(ctx, c, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if e.Identity != d.Identity || e.Cluster != d.Cluster {
+		t.Errorf("expected %+v, got identity %q, cluster %q", d, e.Identity, e.Cluster)
+	}
+}
+
+func TestSetBackoff_description(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		logger = klog.Background()
+		c      = newMemoryCache(t)
+		d      = Description{Identity: "client_id", Cluster: "region/cluster"}
+	)
+
+	fail := func(ctx context.Context) (*Token, error) {
+		return nil, errors.New("unauthorized")
+	}
+
+	_, err := GetCached(WithDescription(ctx, d), logger, c, "gcp_key", fail)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	e, err := InspectEntry(ctx, c, "gcp_key"+BackoffKeySuffix)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if e.Provider != "gcp" || e.Identity != d.Identity || e.Cluster != d.Cluster {
+		t.Errorf("expected described backoff, got %+v", e)
+	}
+}
@@ -0,0 +1,215 @@
+package token
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/seal-io/kubecia/pkg/json"
+)
+
+const (
+	// EnvelopeVersion is the current version of the cache entry envelope.
+	EnvelopeVersion = 1
+
+	// EnvelopeKindToken indicates the envelope holds a Token.
+	EnvelopeKindToken = "token"
+	// EnvelopeKindBackoff indicates the envelope holds a BackoffError.
+	EnvelopeKindBackoff = "backoff"
+)
+
+// envelopeMagic prefixes the envelope,
+// which never collides with the legacy gob or JSON entries.
+var envelopeMagic = []byte{0xff, 'K', 'C', 'E'}
+
+// Envelope is the self-describing format of the cache entry,
+// which is encoded as magic, version, length-prefixed JSON header, and payload.
+type Envelope struct {
+	// Version is the version of the envelope,
+	// zero if upgraded from the legacy entry.
+	Version int `json:"-"`
+	// Kind is the kind of the payload, select from "token" and "backoff".
+	Kind string `json:"kind"`
+	// Provider is the provider of the entry.
+	Provider string `json:"provider"`
+	// Identity is the identity requested the entry, see Description.
+	Identity string `json:"identity,omitempty"`
+	// Cluster is the cluster served by the entry, see Description.
+	Cluster string `json:"cluster,omitempty"`
+	// CreatedAt is the time the entry created.
+	CreatedAt time.Time `json:"createdAt,omitempty"`
+	// Expiration is the expiration of the payload.
+	Expiration time.Time `json:"expiration,omitempty"`
+	// Verifier is the verifier of the secret presented to create the entry,
+	// see WithVerifier.
+	Verifier string `json:"verifier,omitempty"`
+
+	// Payload is the JSON encoded payload,
+	// which tolerates the fields added or removed in future.
+	Payload []byte `json:"-"`
+}
+
+// NewTokenEnvelope returns the Envelope of the given Token of the given key.
+func NewTokenEnvelope(key string, tk *Token) (*Envelope, error) {
+	bs, err := tk.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		Version:    EnvelopeVersion,
+		Kind:       EnvelopeKindToken,
+		Provider:   providerOf(key),
+		CreatedAt:  time.Now(),
+		Expiration: tk.expiration(),
+		Payload:    bs,
+	}, nil
+}
+
+// NewBackoffEnvelope returns the Envelope of the given BackoffError of the given key.
+func NewBackoffEnvelope(key string, be *BackoffError) *Envelope {
+	return &Envelope{
+		Version:    EnvelopeVersion,
+		Kind:       EnvelopeKindBackoff,
+		Provider:   providerOf(key),
+		CreatedAt:  time.Now(),
+		Expiration: be.RetryAt,
+		Payload:    json.MustMarshal(be),
+	}
+}
+
+// Describe records the given description into the header.
+func (e *Envelope) Describe(d Description) {
+	e.Identity = d.Identity
+	e.Cluster = d.Cluster
+}
+
+// providerOf returns the provider of the given key,
+// which is the first segment of the key, see the Key method of the TokenOptions of each provider.
+func providerOf(key string) string {
+	p, _, _ := strings.Cut(key, "_")
+	return p
+}
+
+// MarshalBinary encodes the Envelope with the current version.
+func (e *Envelope) MarshalBinary() ([]byte, error) {
+	hdr, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	buf.Grow(len(envelopeMagic) + 1 + binary.MaxVarintLen64 + len(hdr) + len(e.Payload))
+	buf.Write(envelopeMagic)
+	buf.WriteByte(EnvelopeVersion)
+	buf.Write(binary.AppendUvarint(nil, uint64(len(hdr))))
+	buf.Write(hdr)
+	buf.Write(e.Payload)
+
+	return buf.Bytes(), nil
+}
+
+// Token decodes the payload as a Token.
+func (e *Envelope) Token() (*Token, error) {
+	if e.Kind != EnvelopeKindToken {
+		return nil, fmt.Errorf("invalid envelope kind %q: not a token", e.Kind)
+	}
+
+	var tk Token
+	if err := tk.UnmarshalJSON(e.Payload); err != nil {
+		return nil, fmt.Errorf("error decoding token: %w", err)
+	}
+
+	return &tk, nil
+}
+
+// Backoff decodes the payload as a BackoffError.
+func (e *Envelope) Backoff() (*BackoffError, error) {
+	if e.Kind != EnvelopeKindBackoff {
+		return nil, fmt.Errorf("invalid envelope kind %q: not a backoff", e.Kind)
+	}
+
+	var be BackoffError
+	if err := json.Unmarshal(e.Payload, &be); err != nil {
+		return nil, fmt.Errorf("error decoding backoff: %w", err)
+	}
+
+	return &be, nil
+}
+
+// DecodeEnvelope decodes the cache entry of the given key,
+// and upgrades the entry saved before the envelope introduced,
+// the payload is not decoded, so that the metadata is readable without knowing the payload.
+func DecodeEnvelope(key string, bs []byte) (*Envelope, error) {
+	if !bytes.HasPrefix(bs, envelopeMagic) {
+		return upgradeEnvelope(key, bs)
+	}
+
+	bs = bs[len(envelopeMagic):]
+	if len(bs) == 0 {
+		return nil, errors.New("invalid envelope: truncated")
+	}
+
+	ver := int(bs[0])
+	if ver == 0 {
+		return nil, errors.New("invalid envelope: zero version")
+	}
+
+	if ver > EnvelopeVersion {
+		return nil, fmt.Errorf("unsupported envelope version %d, upgrade to read it", ver)
+	}
+
+	bs = bs[1:]
+
+	n, sz := binary.Uvarint(bs)
+	if sz <= 0 || uint64(len(bs)-sz) < n {
+		return nil, errors.New("invalid envelope: truncated header")
+	}
+
+	var e Envelope
+	if err := json.Unmarshal(bs[sz:sz+int(n)], &e); err != nil {
+		return nil, fmt.Errorf("error decoding envelope header: %w", err)
+	}
+
+	e.Version = ver
+	e.Payload = bs[sz+int(n):]
+
+	return &e, nil
+}
+
+// upgradeEnvelope wraps the legacy entry into an Envelope,
+// the legacy token is gob encoded, and the legacy backoff is JSON encoded.
+func upgradeEnvelope(key string, bs []byte) (*Envelope, error) {
+	if bytes.HasPrefix(bs, []byte("{")) {
+		var be BackoffError
+		if err := json.Unmarshal(bs, &be); err != nil {
+			return nil, fmt.Errorf("error decoding legacy backoff: %w", err)
+		}
+
+		e := NewBackoffEnvelope(key, &be)
+		e.Version = 0
+		e.CreatedAt = time.Time{}
+
+		return e, nil
+	}
+
+	var tk Token
+	if err := tk.UnmarshalBinary(bs); err != nil {
+		return nil, fmt.Errorf("error decoding legacy token: %w", err)
+	}
+
+	e, err := NewTokenEnvelope(key, &tk)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unknown for the legacy entry.
+	e.Version = 0
+	e.CreatedAt = time.Time{}
+
+	return e, nil
+}
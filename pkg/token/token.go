@@ -2,7 +2,9 @@ package token
 
 import (
 	"bytes"
+	"crypto/x509"
 	"encoding/gob"
+	"encoding/pem"
 	"time"
 
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -20,6 +22,13 @@ type (
 	Token struct {
 		Expiration time.Time `json:"expiration,omitempty"`
 		Value      string    `json:"value"`
+		// ClientCertificateData holds the PEM-encoded client certificate,
+		// it is used together with ClientKeyData.
+		ClientCertificateData string `json:"clientCertificateData,omitempty"`
+		// ClientKeyData holds the PEM-encoded client private key.
+		ClientKeyData string `json:"clientKeyData,omitempty"`
+
+		stale bool
 	}
 
 	// _Token alias Token, see https://github.com/golang/go/issues/32251.
@@ -27,11 +36,85 @@ type (
 )
 
 func (t *Token) Expired() bool {
-	if t.Expiration.IsZero() {
+	exp := t.expiration()
+	if exp.IsZero() {
 		return true
 	}
 
-	return t.Expiration.Before(time.Now())
+	return exp.Before(time.Now())
+}
+
+// ExpirationMargin is the safety margin before the token expiration,
+// a cached token is refreshed at this margin ahead of its expiration.
+const ExpirationMargin = 1 * time.Minute
+
+// SoftExpired returns true if the token is within ExpirationMargin ahead of its expiration,
+// which should be refreshed, but is still usable if refreshing failed.
+func (t *Token) SoftExpired() bool {
+	exp := t.expiration()
+	if exp.IsZero() {
+		return true
+	}
+
+	return exp.Add(-ExpirationMargin).Before(time.Now())
+}
+
+// Stale returns true if the token is served after failing to refresh.
+func (t *Token) Stale() bool {
+	return t.stale
+}
+
+// MarkStale marks the token is served after failing to refresh.
+func (t *Token) MarkStale() {
+	t.stale = true
+}
+
+// CacheTTL returns the duration to keep the token in cache,
+// which is the time until the expiration,
+// so that the token can be served stale if refreshing failed,
+// returns non-positive if the token should not be cached.
+func (t *Token) CacheTTL() time.Duration {
+	exp := t.expiration()
+	if exp.IsZero() {
+		return 0
+	}
+
+	return time.Until(exp)
+}
+
+// HasClientCertificate returns true if the token carries a client certificate and key pair.
+func (t *Token) HasClientCertificate() bool {
+	return t.ClientCertificateData != "" && t.ClientKeyData != ""
+}
+
+// ClientCertificateExpiration returns the expiration of the client certificate,
+// returns zero if no client certificate or failed to parse.
+func (t *Token) ClientCertificateExpiration() time.Time {
+	if !t.HasClientCertificate() {
+		return time.Time{}
+	}
+
+	b, _ := pem.Decode([]byte(t.ClientCertificateData))
+	if b == nil || b.Type != "CERTIFICATE" {
+		return time.Time{}
+	}
+
+	c, err := x509.ParseCertificate(b.Bytes)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return c.NotAfter
+}
+
+// expiration returns the expiration of the token,
+// falls back to the expiration of the client certificate if not specified.
+func (t *Token) expiration() time.Time {
+	if !t.Expiration.IsZero() {
+		return t.Expiration
+	}
+
+	return t.ClientCertificateExpiration()
 }
 
 func (t *Token) MarshalBinary() ([]byte, error) {
@@ -61,7 +144,7 @@ func (t *Token) UnmarshalBinary(b []byte) error {
 func (t *Token) MarshalJSON() ([]byte, error) {
 	var buf bytes.Buffer
 
-	err := json.NewEncoder(&buf).Encode(*t)
+	err := json.NewEncoder(&buf).Encode(_Token(*t))
 	if err != nil {
 		return nil, err
 	}
@@ -70,20 +153,29 @@ func (t *Token) MarshalJSON() ([]byte, error) {
 }
 
 func (t *Token) UnmarshalJSON(b []byte) error {
-	return json.NewDecoder(bytes.NewReader(b)).Decode(t)
+	// Decode into the alias to avoid recursing into this method.
+	return json.NewDecoder(bytes.NewReader(b)).Decode((*_Token)(t))
 }
 
 func (t *Token) ToKubeClientExecCredential() clientauth.ExecCredential {
-	return clientauth.ExecCredential{
+	ec := clientauth.ExecCredential{
 		TypeMeta: meta.TypeMeta{
 			APIVersion: clientauth.SchemeGroupVersion.String(),
 			Kind:       "ExecCredential",
 		},
 		Status: &clientauth.ExecCredentialStatus{
-			ExpirationTimestamp: ptr.To(meta.NewTime(t.Expiration)),
+			ExpirationTimestamp: ptr.To(meta.NewTime(t.expiration())),
 			Token:               t.Value,
 		},
 	}
+
+	// Client certificate and key must be present at the same time.
+	if t.HasClientCertificate() {
+		ec.Status.ClientCertificateData = t.ClientCertificateData
+		ec.Status.ClientKeyData = t.ClientKeyData
+	}
+
+	return ec
 }
 
 func (t *Token) ToKubeClientExecCredentialJSON() ([]byte, error) {